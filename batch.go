@@ -0,0 +1,162 @@
+/*
+ * 批量查询
+ * 将并发的单个查询在短时间窗口内合并为一次批量查询
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package cachex
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrBatchNoResult 批量查询返回的错误数量与请求数量不一致时，缺少结果的请求得到该错误
+var ErrBatchNoResult = errors.New("no result in batch")
+
+// queryBatch 一次批量查询
+type queryBatch struct {
+	// ctx 批次内第一个查询的ctx，只取其中的值
+	ctx context.Context
+	// deadline 批次内最晚的截止时间。有查询没有截止时间时，hasDeadline为false
+	deadline    time.Time
+	hasDeadline bool
+
+	requests []interface{}
+	values   []interface{}
+	errs     []error
+
+	done chan struct{}
+}
+
+// batchQuerier 合并并发的单个查询为批量查询，实现Querier接口
+type batchQuerier struct {
+	querier BatchQuerier
+
+	window   time.Duration
+	maxBatch int
+
+	lock    sync.Mutex
+	pending *queryBatch
+}
+
+// NewBatchQuerier 新建合并查询过程。
+// 返回的Querier收集window时间窗口内的查询，或收集满maxBatch个（maxBatch不大于0时不限制）后，合并为一次批量查询。
+// 作为Cachex的查询过程使用时，相同key的并发查询已由哨兵合并，批量查询中不会出现重复的key。
+// 批量查询不随单个查询的ctx取消：携带批次内第一个查询的ctx的值，截止时间为批次内最晚的截止时间（有查询没有截止时间时不设置）。
+// 取消或超时的查询不再等待批量查询，不影响同批次的其它查询。
+func NewBatchQuerier(querier BatchQuerier, window time.Duration, maxBatch int) Querier {
+	return &batchQuerier{
+		querier:  querier,
+		window:   window,
+		maxBatch: maxBatch,
+	}
+}
+
+// Query 实现Querier接口。加入当前批次，等待批量查询完成
+func (q *batchQuerier) Query(ctx context.Context, request, value interface{}) error {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		panic("value not is non-nil pointer")
+	}
+	// 批量查询写入独立的变量，避免放弃等待的调用者的value被写入
+	result := reflect.New(v.Type().Elem())
+
+	q.lock.Lock()
+	batch := q.pending
+	if batch == nil {
+		batch = &queryBatch{
+			ctx:  ctx,
+			done: make(chan struct{}),
+		}
+		batch.deadline, batch.hasDeadline = ctx.Deadline()
+		q.pending = batch
+		time.AfterFunc(q.window, func() {
+			q.dispatch(batch)
+		})
+	}
+	if deadline, ok := ctx.Deadline(); !ok {
+		batch.hasDeadline = false
+	} else if deadline.After(batch.deadline) {
+		batch.deadline = deadline
+	}
+	idx := len(batch.requests)
+	batch.requests = append(batch.requests, request)
+	batch.values = append(batch.values, result.Interface())
+	full := q.maxBatch > 0 && len(batch.requests) >= q.maxBatch
+	q.lock.Unlock()
+
+	if full {
+		// 在独立的goroutine中发起，填满批次的查询同样可以随自己的ctx返回
+		go q.dispatch(batch)
+	}
+
+	select {
+	case <-batch.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if err := batch.errs[idx]; err != nil {
+		return err
+	}
+	v.Elem().Set(result.Elem())
+	return nil
+}
+
+// dispatch 发起批量查询。同一批次只发起一次
+func (q *batchQuerier) dispatch(batch *queryBatch) {
+	q.lock.Lock()
+	if q.pending != batch {
+		// 已发起
+		q.lock.Unlock()
+		return
+	}
+	q.pending = nil
+	q.lock.Unlock()
+
+	// 确保等待的查询总是能得到结果
+	batch.errs = make([]error, len(batch.requests))
+	for idx := range batch.errs {
+		batch.errs[idx] = ErrBatchNoResult
+	}
+	defer close(batch.done)
+
+	var ctx context.Context = detachedContext{batch.ctx}
+	if batch.hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, batch.deadline)
+		defer cancel()
+	}
+
+	errs := q.querier.QueryBatch(ctx, batch.requests, batch.values)
+	for idx := range batch.errs {
+		if errs == nil {
+			batch.errs[idx] = nil
+		} else if idx < len(errs) {
+			batch.errs[idx] = errs[idx]
+		}
+	}
+}
+
+// detachedContext 保留父ctx的值，但不随父ctx取消、超时
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
@@ -0,0 +1,173 @@
+package cachex
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex/mock_cachex"
+)
+
+func TestBatchQuerier(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+
+	notFound := mock_cachex.NewMockNotFound(ctrl)
+
+	var lock sync.Mutex
+	var batches [][]int
+	mockQuery := mock_cachex.NewMockBatchQuerier(ctrl)
+	mockQuery.EXPECT().QueryBatch(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, requests, values []interface{}) []error {
+		var batch []int
+		errs := make([]error, len(requests))
+		for idx, request := range requests {
+			num := request.(int)
+			batch = append(batch, num)
+			if num < 0 {
+				errs[idx] = notFound
+				continue
+			}
+			reflect.ValueOf(values[idx]).Elem().Set(reflect.ValueOf(num * num))
+		}
+		sort.Ints(batch)
+
+		lock.Lock()
+		defer lock.Unlock()
+		batches = append(batches, batch)
+		return errs
+	}).AnyTimes()
+
+	c := NewCachex(NopStorage{}, NewBatchQuerier(mockQuery, time.Millisecond*50, 0))
+
+	// 并发的单个查询，合并为一次批量查询，重复的key只查询一次
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(num int) {
+			defer wg.Done()
+
+			var value int
+			err := c.Get(ctx, num, &value)
+			assert.NoError(t, err)
+			assert.Equal(t, num*num, value)
+		}(i % 10)
+	}
+	wg.Wait()
+	assert.Equal(t, [][]int{{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}}, batches)
+
+	// 没找到
+	var value int
+	err := c.Get(ctx, -1, &value)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestBatchQuerierMaxBatch(t *testing.T) {
+	ctx := context.Background()
+
+	var lock sync.Mutex
+	var sizes []int
+	query := BatchQueryFunc(func(ctx context.Context, requests, values []interface{}) []error {
+		for idx, request := range requests {
+			reflect.ValueOf(values[idx]).Elem().Set(reflect.ValueOf(request))
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		sizes = append(sizes, len(requests))
+		return nil
+	})
+
+	// 时间窗口足够长，按批次大小发起
+	c := NewCachex(NopStorage{}, NewBatchQuerier(query, time.Minute, 5))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(num int) {
+			defer wg.Done()
+
+			var value int
+			err := c.Get(ctx, num, &value)
+			assert.NoError(t, err)
+			assert.Equal(t, num, value)
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, []int{5, 5}, sizes)
+}
+
+func TestBatchQuerierCancel(t *testing.T) {
+	type ctxKey struct{}
+
+	release := make(chan struct{})
+	query := BatchQueryFunc(func(ctx context.Context, requests, values []interface{}) []error {
+		<-release
+		// 批量查询不随第一个查询取消，保留其值
+		assert.NoError(t, ctx.Err())
+		assert.Equal(t, "first", ctx.Value(ctxKey{}))
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok)
+		assert.True(t, time.Until(deadline) > time.Minute)
+
+		for idx, request := range requests {
+			reflect.ValueOf(values[idx]).Elem().Set(reflect.ValueOf(request))
+		}
+		return nil
+	})
+	querier := NewBatchQuerier(query, time.Minute, 2)
+
+	first, cancel := context.WithTimeout(context.WithValue(context.Background(), ctxKey{}, "first"), time.Millisecond*20)
+	defer cancel()
+	firstDone := make(chan error)
+	go func() {
+		var value int
+		firstDone <- querier.Query(first, 1, &value)
+	}()
+	time.Sleep(time.Millisecond * 5)
+
+	second, cancel2 := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel2()
+	secondDone := make(chan error)
+	var value int
+	go func() {
+		secondDone <- querier.Query(second, 2, &value)
+	}()
+
+	// 第一个查询超时，不影响第二个查询
+	assert.Equal(t, context.DeadlineExceeded, <-firstDone)
+	close(release)
+	assert.NoError(t, <-secondDone)
+	assert.Equal(t, 2, value)
+}
+
+func TestBatchQuerierFullCancel(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	query := BatchQueryFunc(func(ctx context.Context, requests, values []interface{}) []error {
+		<-release
+		return nil
+	})
+	querier := NewBatchQuerier(query, time.Minute, 1)
+
+	// 填满批次的查询超时，不等待批量查询完成
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		var value int
+		done <- querier.Query(ctx, 1, &value)
+	}()
+	select {
+	case err := <-done:
+		assert.Equal(t, context.DeadlineExceeded, err)
+	case <-time.After(time.Second):
+		t.Fatal("query blocked by the batch query")
+	}
+}
@@ -0,0 +1,503 @@
+/*
+ * bbolt磁盘存储支持
+ * 缓存数据持久化到本地文件，重启后仍然可用
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package boltcache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/wencan/cachex"
+	bolt "go.etcd.io/bbolt"
+)
+
+// NotFound 没找到错误
+type NotFound struct{}
+
+// NotFound 实现cachex.NotFound错误接口
+func (NotFound) NotFound() {}
+func (NotFound) Error() string {
+	return "not found"
+}
+
+// Expired 数据已过期错误
+type Expired struct{}
+
+// Expired 实现cachex.Expired错误接口
+func (Expired) Expired() {}
+func (Expired) Error() string {
+	return "expired"
+}
+
+var notFound = NotFound{}
+var expired = Expired{}
+
+// ErrMalformedRecord 无法解析的记录
+var ErrMalformedRecord = errors.New("malformed record")
+
+var (
+	// dataBucket key到记录的映射
+	dataBucket = []byte("data")
+	// expiryBucket 过期索引，过期时间（8字节）+key，按过期时间有序
+	expiryBucket = []byte("expiry")
+	// metaBucket 元数据
+	metaBucket = []byte("meta")
+	// sizeKey 全部记录的key和记录的总字节数
+	sizeKey = []byte("size")
+)
+
+// recordHeaderSize 记录头长度：写入时间(8) + 过期时间(8)
+const recordHeaderSize = 8 + 8
+
+// neverExpire 不过期的数据在过期索引中的过期时间，最后淘汰
+const neverExpire = math.MaxInt64
+
+// DefaultCompactInterval 默认的后台清理间隔
+const DefaultCompactInterval = time.Minute
+
+// BoltCache bbolt磁盘缓存类，实现了cachex.DeletableStorage接口、cachex.ClearableStorage接口和cachex.SetWithTTLableStorage接口
+type BoltCache struct {
+	db *bolt.DB
+	// ownDB db由BoltCache打开，Close时关闭
+	ownDB bool
+
+	defaultTTL time.Duration
+
+	// staleTTL 数据过期后保留的时长，保留期间返回过期数据和Expired错误
+	staleTTL time.Duration
+
+	// maxSize 全部记录的最大字节数，为0时不限制
+	maxSize int64
+
+	// jitter 写入时的TTL抖动策略
+	jitter cachex.Jitter
+
+	// keyFunc key规范化函数
+	keyFunc cachex.KeyFunc
+
+	codec cachex.Codec
+
+	closeOnce sync.Once
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+type boltOptions struct {
+	defaultTTL time.Duration
+
+	staleTTL time.Duration
+
+	maxSize int64
+
+	compactInterval time.Duration
+
+	jitter cachex.Jitter
+
+	keyFunc cachex.KeyFunc
+
+	codec cachex.Codec
+}
+
+// BoltOption boltcache配置
+type BoltOption struct {
+	f func(*boltOptions)
+}
+
+// BoltDefaultTTLOption 配置默认TTL。默认不过期
+func BoltDefaultTTLOption(defaultTTL time.Duration) BoltOption {
+	return BoltOption{func(options *boltOptions) {
+		options.defaultTTL = defaultTTL
+	}}
+}
+
+// BoltStaleTTLOption 配置数据过期后保留的时长。
+// 保留期间Get返回过期数据和Expired错误（查询出错时可使用过期数据），保留期过后由后台清理。默认为0，过期数据保留到下一次清理
+func BoltStaleTTLOption(staleTTL time.Duration) BoltOption {
+	return BoltOption{func(options *boltOptions) {
+		options.staleTTL = staleTTL
+	}}
+}
+
+// BoltMaxSizeOption 配置全部记录（key和数据）的最大字节数，超过时淘汰最先过期的数据，不过期的数据最后淘汰。默认不限制。
+// 超过最大字节数的单个记录不写入，并删除key已有的记录。
+// bbolt删除数据后文件不会缩小（空闲页会被复用），文件大小可能大于maxSize
+func BoltMaxSizeOption(maxSize int64) BoltOption {
+	return BoltOption{func(options *boltOptions) {
+		options.maxSize = maxSize
+	}}
+}
+
+// BoltCompactIntervalOption 配置后台清理过期数据的间隔，默认为DefaultCompactInterval。小于0时不在后台清理
+func BoltCompactIntervalOption(interval time.Duration) BoltOption {
+	return BoltOption{func(options *boltOptions) {
+		options.compactInterval = interval
+	}}
+}
+
+// BoltTTLJitterOption 配置写入时的TTL抖动策略，作用于每一次写入（Cachex已抖动过的TTL除外，见cachex.JitterTTL）。默认不抖动
+func BoltTTLJitterOption(jitter cachex.Jitter) BoltOption {
+	return BoltOption{func(options *boltOptions) {
+		options.jitter = jitter
+	}}
+}
+
+// BoltKeyFuncOption 配置key规范化函数，在转为字符串前调用
+func BoltKeyFuncOption(keyFunc cachex.KeyFunc) BoltOption {
+	return BoltOption{func(options *boltOptions) {
+		options.keyFunc = keyFunc
+	}}
+}
+
+// BoltCodecOption 配置编解码器。默认使用cachex.MsgpackCodec
+func BoltCodecOption(codec cachex.Codec) BoltOption {
+	return BoltOption{func(options *boltOptions) {
+		options.codec = codec
+	}}
+}
+
+// NewBoltCache 打开（不存在则创建）path的bbolt数据库，创建磁盘缓存对象。
+// 同一个文件同时只能被一个进程打开，打开超时返回错误。使用完毕需调用Close
+func NewBoltCache(path string, options ...BoltOption) (*BoltCache, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := NewBoltCacheWithDB(db, options...)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	c.ownDB = true
+	return c, nil
+}
+
+// NewBoltCacheWithDB 使用已打开的bbolt数据库创建磁盘缓存对象。Close不关闭db
+func NewBoltCacheWithDB(db *bolt.DB, options ...BoltOption) (*BoltCache, error) {
+	opts := boltOptions{
+		compactInterval: DefaultCompactInterval,
+		codec:           cachex.MsgpackCodec{},
+	}
+	for _, option := range options {
+		option.f(&opts)
+	}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{dataBucket, expiryBucket, metaBucket} {
+			_, err := tx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &BoltCache{
+		db:         db,
+		defaultTTL: opts.defaultTTL,
+		staleTTL:   opts.staleTTL,
+		maxSize:    opts.maxSize,
+		jitter:     opts.jitter,
+		keyFunc:    opts.keyFunc,
+		codec:      opts.codec,
+		done:       make(chan struct{}),
+	}
+	if opts.compactInterval > 0 {
+		c.wg.Add(1)
+		go c.compactLoop(opts.compactInterval)
+	}
+	return c, nil
+}
+
+// Close 停止后台清理。数据库由NewBoltCache打开时，关闭数据库
+func (c *BoltCache) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.wg.Wait()
+		if c.ownDB {
+			err = c.db.Close()
+		}
+	})
+	return err
+}
+
+// stringKey 将interface{} key转为字节串，不支持类型返回错误
+// 结构体、切片、map等key编码为确定的字符串，见cachex.KeyString
+func (c *BoltCache) stringKey(key interface{}) ([]byte, error) {
+	skey, err := cachex.KeyStringFunc(c.keyFunc, key)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(skey), nil
+}
+
+// Set 设置缓存数据
+func (c *BoltCache) Set(ctx context.Context, key, value interface{}) error {
+	return c.SetWithTTL(ctx, key, value, c.defaultTTL)
+}
+
+// SetWithTTL 设置缓存数据，并定制TTL。覆盖已存在的数据。超过最大字节数时淘汰最先过期的数据。
+// bbolt不支持取消，ctx在开始事务前和得到写锁后检查
+func (c *BoltCache) SetWithTTL(ctx context.Context, key, value interface{}, TTL time.Duration) error {
+	bkey, err := c.stringKey(key)
+	if err != nil {
+		return err
+	}
+
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	TTL = cachex.JitterTTL(ctx, c.jitter, TTL)
+
+	now := time.Now()
+	expireTime := int64(neverExpire)
+	if TTL != 0 {
+		expireTime = now.Add(TTL).UnixNano()
+	}
+
+	record := make([]byte, recordHeaderSize+len(data))
+	binary.BigEndian.PutUint64(record, uint64(now.UnixNano()))
+	binary.BigEndian.PutUint64(record[8:], uint64(expireTime))
+	copy(record[recordHeaderSize:], data)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.db.Update(func(tx *bolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		size := readSize(tx)
+
+		err := c.remove(tx, bkey, &size)
+		if err != nil {
+			return err
+		}
+
+		if c.maxSize > 0 && int64(len(bkey)+len(record)) > c.maxSize {
+			// 超过最大字节数的单个记录不写入，不淘汰其它数据
+			return writeSize(tx, size)
+		}
+
+		err = tx.Bucket(dataBucket).Put(bkey, record)
+		if err != nil {
+			return err
+		}
+		err = tx.Bucket(expiryBucket).Put(expiryKey(expireTime, bkey), nil)
+		if err != nil {
+			return err
+		}
+		size += int64(len(bkey) + len(record))
+
+		if c.maxSize > 0 && size > c.maxSize {
+			err = c.evict(tx, &size)
+			if err != nil {
+				return err
+			}
+		}
+
+		return writeSize(tx, size)
+	})
+}
+
+// Get 获取缓存数据。数据已过期、在保留期内时，返回过期数据和Expired错误
+func (c *BoltCache) Get(ctx context.Context, key, value interface{}) error {
+	if v := reflect.ValueOf(value); v.Kind() != reflect.Ptr || v.IsNil() {
+		panic("value not is non-nil pointer")
+	}
+
+	bkey, err := c.stringKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var data []byte
+	var expireTime int64
+	err = c.db.View(func(tx *bolt.Tx) error {
+		record := tx.Bucket(dataBucket).Get(bkey)
+		if record == nil {
+			return notFound
+		}
+		if len(record) < recordHeaderSize {
+			return ErrMalformedRecord
+		}
+
+		expireTime = int64(binary.BigEndian.Uint64(record[8:]))
+		// 记录只在事务内有效
+		data = append([]byte(nil), record[recordHeaderSize:]...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UnixNano()
+	if expireTime != neverExpire && now > expireTime+int64(c.staleTTL) {
+		// 已超过保留期，等待清理
+		return notFound
+	}
+
+	err = c.codec.Unmarshal(data, value)
+	if err != nil {
+		return err
+	}
+
+	if expireTime != neverExpire && now > expireTime {
+		// 返回过期数据同时，返回expired错误
+		return expired
+	}
+	return nil
+}
+
+// Del 删除缓存数据
+func (c *BoltCache) Del(ctx context.Context, keys ...interface{}) error {
+	bkeys := make([][]byte, len(keys))
+	for idx, key := range keys {
+		bkey, err := c.stringKey(key)
+		if err != nil {
+			return err
+		}
+		bkeys[idx] = bkey
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.db.Update(func(tx *bolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		size := readSize(tx)
+		for _, bkey := range bkeys {
+			err := c.remove(tx, bkey, &size)
+			if err != nil {
+				return err
+			}
+		}
+		return writeSize(tx, size)
+	})
+}
+
+// Clear 清空缓存的数据
+func (c *BoltCache) Clear(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.db.Update(func(tx *bolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, name := range [][]byte{dataBucket, expiryBucket} {
+			err := tx.DeleteBucket(name)
+			if err != nil {
+				return err
+			}
+			_, err = tx.CreateBucket(name)
+			if err != nil {
+				return err
+			}
+		}
+		return writeSize(tx, 0)
+	})
+}
+
+// Size 全部记录（key和数据）的字节数
+func (c *BoltCache) Size() (int64, error) {
+	var size int64
+	err := c.db.View(func(tx *bolt.Tx) error {
+		size = readSize(tx)
+		return nil
+	})
+	return size, err
+}
+
+// remove 删除key的记录和过期索引，并减去记录的字节数
+func (c *BoltCache) remove(tx *bolt.Tx, bkey []byte, size *int64) error {
+	data := tx.Bucket(dataBucket)
+	record := data.Get(bkey)
+	if record == nil {
+		return nil
+	}
+	if len(record) >= recordHeaderSize {
+		expireTime := int64(binary.BigEndian.Uint64(record[8:]))
+		err := tx.Bucket(expiryBucket).Delete(expiryKey(expireTime, bkey))
+		if err != nil {
+			return err
+		}
+	}
+	*size -= int64(len(bkey) + len(record))
+	return data.Delete(bkey)
+}
+
+// evict 按过期时间从早到晚淘汰数据，直到不超过最大字节数
+func (c *BoltCache) evict(tx *bolt.Tx, size *int64) error {
+	expiry := tx.Bucket(expiryBucket)
+	for *size > c.maxSize {
+		k, _ := expiry.Cursor().First()
+		if k == nil {
+			break
+		}
+		// 修改bucket后k失效
+		err := c.removeIndexed(tx, append([]byte(nil), k...), size)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeIndexed 删除过期索引k和对应的记录。记录的过期时间与索引不一致时只删除残留的索引
+func (c *BoltCache) removeIndexed(tx *bolt.Tx, k []byte, size *int64) error {
+	bkey := k[8:]
+	record := tx.Bucket(dataBucket).Get(bkey)
+	if len(record) >= recordHeaderSize && binary.BigEndian.Uint64(record[8:]) == binary.BigEndian.Uint64(k) {
+		return c.remove(tx, bkey, size)
+	}
+	return tx.Bucket(expiryBucket).Delete(k)
+}
+
+// expiryKey 过期索引的key
+func expiryKey(expireTime int64, bkey []byte) []byte {
+	k := make([]byte, 8+len(bkey))
+	binary.BigEndian.PutUint64(k, uint64(expireTime))
+	copy(k[8:], bkey)
+	return k
+}
+
+// readSize 读取全部记录的字节数
+func readSize(tx *bolt.Tx) int64 {
+	v := tx.Bucket(metaBucket).Get(sizeKey)
+	if len(v) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(v))
+}
+
+// writeSize 保存全部记录的字节数
+func writeSize(tx *bolt.Tx, size int64) error {
+	if size < 0 {
+		size = 0
+	}
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, uint64(size))
+	return tx.Bucket(metaBucket).Put(sizeKey, v)
+}
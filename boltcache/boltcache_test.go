@@ -0,0 +1,287 @@
+package boltcache
+
+// wencan
+// 2026-10-19
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex"
+)
+
+// tempPath 返回临时目录下的数据库路径和清理函数
+func tempPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "boltcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "cache.db"), func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestBoltCache(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	cache, err := NewBoltCache(path, BoltDefaultTTLOption(time.Minute))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cache.Close()
+	assert.Implements(t, (*cachex.DeletableStorage)(nil), cache)
+	assert.Implements(t, (*cachex.ClearableStorage)(nil), cache)
+	assert.Implements(t, (*cachex.SetWithTTLableStorage)(nil), cache)
+
+	var value string
+	err = cache.Get(ctx, "key", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+
+	err = cache.Set(ctx, "key", "value")
+	assert.NoError(t, err)
+	err = cache.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	err = cache.SetWithTTL(ctx, "forever", "forever", 0)
+	assert.NoError(t, err)
+
+	err = cache.Del(ctx, "key", "none")
+	assert.NoError(t, err)
+	err = cache.Get(ctx, "key", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+
+	size, err := cache.Size()
+	assert.NoError(t, err)
+	assert.True(t, size > 0)
+
+	err = cache.Clear(ctx)
+	assert.NoError(t, err)
+	err = cache.Get(ctx, "forever", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	size, err = cache.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), size)
+}
+
+func TestBoltCacheReopen(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	cache, err := NewBoltCache(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = cache.Set(ctx, "key", "value")
+	assert.NoError(t, err)
+	size, err := cache.Size()
+	assert.NoError(t, err)
+	err = cache.Close()
+	assert.NoError(t, err)
+
+	// 重启后数据仍然可用
+	cache, err = NewBoltCache(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cache.Close()
+
+	var value string
+	err = cache.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+	reopened, err := cache.Size()
+	assert.NoError(t, err)
+	assert.Equal(t, size, reopened)
+}
+
+func TestBoltCacheExpired(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	cache, err := NewBoltCache(path, BoltStaleTTLOption(time.Millisecond*100), BoltCompactIntervalOption(-1))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cache.Close()
+
+	err = cache.SetWithTTL(ctx, "key", "value", time.Millisecond*10)
+	assert.NoError(t, err)
+	err = cache.SetWithTTL(ctx, "forever", "forever", 0)
+	assert.NoError(t, err)
+
+	// 过期，保留期内返回过期数据
+	time.Sleep(time.Millisecond * 20)
+	var value string
+	err = cache.Get(ctx, "key", &value)
+	assert.Implements(t, (*cachex.Expired)(nil), err)
+	assert.Equal(t, "value", value)
+
+	// 保留期内不清理
+	err = cache.Compact(ctx)
+	assert.NoError(t, err)
+	err = cache.Get(ctx, "key", &value)
+	assert.Implements(t, (*cachex.Expired)(nil), err)
+
+	// 超过保留期
+	time.Sleep(time.Millisecond * 100)
+	err = cache.Get(ctx, "key", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+
+	before, err := cache.Size()
+	assert.NoError(t, err)
+	err = cache.Compact(ctx)
+	assert.NoError(t, err)
+	after, err := cache.Size()
+	assert.NoError(t, err)
+	assert.True(t, after < before)
+
+	err = cache.Get(ctx, "forever", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "forever", value)
+}
+
+func TestBoltCacheCompactLoop(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	cache, err := NewBoltCache(path, BoltCompactIntervalOption(time.Millisecond*10))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cache.Close()
+
+	for i := 0; i < compactBatch+10; i++ {
+		err = cache.SetWithTTL(ctx, i, i, time.Millisecond)
+		assert.NoError(t, err)
+	}
+
+	assert.Eventually(t, func() bool {
+		size, err := cache.Size()
+		return err == nil && size == 0
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestBoltCacheMaxSize(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	cache, err := NewBoltCache(path, BoltMaxSizeOption(1024))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cache.Close()
+
+	err = cache.SetWithTTL(ctx, "forever", "forever", 0)
+	assert.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		// 越晚写入越早过期
+		err = cache.SetWithTTL(ctx, strconv.Itoa(i), i, time.Hour-time.Duration(i)*time.Second)
+		assert.NoError(t, err)
+
+		size, err := cache.Size()
+		assert.NoError(t, err)
+		assert.True(t, size <= 1024)
+	}
+
+	// 淘汰最先过期的数据，不过期的数据最后淘汰
+	var value int
+	err = cache.Get(ctx, "0", &value)
+	assert.NoError(t, err)
+	err = cache.Get(ctx, "99", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	var forever string
+	err = cache.Get(ctx, "forever", &forever)
+	assert.NoError(t, err)
+
+	// 超过最大字节数的单个记录不写入，不淘汰其它数据，并删除key已有的记录
+	err = cache.Set(ctx, "large", "small")
+	assert.NoError(t, err)
+	size, err := cache.Size()
+	assert.NoError(t, err)
+	err = cache.Set(ctx, "large", string(make([]byte, 2048)))
+	assert.NoError(t, err)
+	var large string
+	err = cache.Get(ctx, "large", &large)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	err = cache.Get(ctx, "0", &value)
+	assert.NoError(t, err)
+	after, err := cache.Size()
+	assert.NoError(t, err)
+	assert.True(t, after < size)
+}
+
+func TestBoltCacheCanceled(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	cache, err := NewBoltCache(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cache.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = cache.Set(ctx, "key", "value")
+	assert.NoError(t, err)
+
+	cancel()
+	err = cache.Set(ctx, "key", "canceled")
+	assert.Equal(t, context.Canceled, err)
+	var value string
+	err = cache.Get(ctx, "key", &value)
+	assert.Equal(t, context.Canceled, err)
+	err = cache.Del(ctx, "key")
+	assert.Equal(t, context.Canceled, err)
+	err = cache.Clear(ctx)
+	assert.Equal(t, context.Canceled, err)
+
+	err = cache.Get(context.Background(), "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+}
+
+func TestBoltCacheCachex(t *testing.T) {
+	path, cleanup := tempPath(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	cache, err := NewBoltCache(path, BoltDefaultTTLOption(time.Minute))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cache.Close()
+
+	queries := 0
+	c := cachex.NewCachex(cache, cachex.QueryFunc(func(ctx context.Context, key, value interface{}) error {
+		queries++
+		*value.(*string) = "value"
+		return nil
+	}))
+
+	for i := 0; i < 3; i++ {
+		var value string
+		err = c.Get(ctx, "key", &value)
+		assert.NoError(t, err)
+		assert.Equal(t, "value", value)
+	}
+	assert.Equal(t, 1, queries)
+}
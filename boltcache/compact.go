@@ -0,0 +1,76 @@
+/*
+ * 后台清理过期数据
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package boltcache
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// compactBatch 每个写事务清理的最大记录数，避免长时间持有写锁
+const compactBatch = 1000
+
+// compactLoop 定时清理过期数据，直到Close
+func (c *BoltCache) compactLoop(interval time.Duration) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			// 清理失败等待下一次
+			_ = c.Compact(context.Background())
+		}
+	}
+}
+
+// Compact 删除已过期、且超过保留期（见BoltStaleTTLOption）的数据。分批在多个写事务中删除。
+// 默认由后台定时调用
+func (c *BoltCache) Compact(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		cutoff := time.Now().Add(-c.staleTTL).UnixNano()
+		var removed int
+		err := c.db.Update(func(tx *bolt.Tx) error {
+			var keys [][]byte
+			cursor := tx.Bucket(expiryBucket).Cursor()
+			for k, _ := cursor.First(); k != nil && len(keys) < compactBatch; k, _ = cursor.Next() {
+				if int64(binary.BigEndian.Uint64(k)) >= cutoff {
+					break
+				}
+				keys = append(keys, append([]byte(nil), k...))
+			}
+
+			size := readSize(tx)
+			for _, k := range keys {
+				err := c.removeIndexed(tx, k, &size)
+				if err != nil {
+					return err
+				}
+			}
+			removed = len(keys)
+			return writeSize(tx, size)
+		})
+		if err != nil {
+			return err
+		}
+		if removed < compactBatch {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,61 @@
+/*
+ * 通过context传递Get可选参数
+ * 以支持HTTP中间件将请求头Cache-Control映射为缓存模式
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package cachex
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getOptionsContextKey context中Get可选参数的key
+type getOptionsContextKey struct{}
+
+// WithGetOptions 返回携带Get可选参数的context。
+// Get会先应用context携带的参数，再应用直接传入的参数。
+func WithGetOptions(ctx context.Context, opts ...GetOption) context.Context {
+	if len(opts) == 0 {
+		return ctx
+	}
+	// 保留上层已携带的参数
+	parent := GetOptionsFromContext(ctx)
+	merged := make([]GetOption, 0, len(parent)+len(opts))
+	merged = append(merged, parent...)
+	merged = append(merged, opts...)
+	return context.WithValue(ctx, getOptionsContextKey{}, merged)
+}
+
+// GetOptionsFromContext 返回context携带的Get可选参数
+func GetOptionsFromContext(ctx context.Context) []GetOption {
+	opts, _ := ctx.Value(getOptionsContextKey{}).([]GetOption)
+	return opts
+}
+
+// CacheControlOptions 将请求头Cache-Control映射为Get可选参数。
+// no-cache映射为GetBypassOption，no-store映射为GetNoStoreOption，max-age=N映射为GetMaxAgeOption。其它指令忽略。
+func CacheControlOptions(header string) []GetOption {
+	var opts []GetOption
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-cache":
+			opts = append(opts, GetBypassOption())
+		case directive == "no-store":
+			opts = append(opts, GetNoStoreOption())
+		case strings.HasPrefix(directive, "max-age="):
+			seconds, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(directive, "max-age="), `"`), 10, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			opts = append(opts, GetMaxAgeOption(time.Duration(seconds)*time.Second))
+		}
+	}
+	return opts
+}
@@ -0,0 +1,57 @@
+package cachex
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex/mock_cachex"
+)
+
+func TestCacheControlOptions(t *testing.T) {
+	var options getOptions
+	for _, opt := range CacheControlOptions("No-Cache, no-store, max-age=60, private") {
+		opt.apply(&options)
+	}
+	assert.True(t, options.bypass)
+	assert.True(t, options.noStore)
+	assert.True(t, options.hasMaxAge)
+	assert.Equal(t, time.Minute, options.maxAge)
+
+	options = getOptions{}
+	for _, opt := range CacheControlOptions("max-age=0") {
+		opt.apply(&options)
+	}
+	assert.True(t, options.bypass)
+	assert.False(t, options.hasMaxAge)
+
+	assert.Empty(t, CacheControlOptions("max-age=abc"))
+}
+
+func TestCachexGetWithContextOptions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	bypassCtx := WithGetOptions(ctx, CacheControlOptions("no-cache")...)
+
+	mockStorage := mock_cachex.NewMockStorage(ctrl)
+	mockStorage.EXPECT().Set(gomock.Eq(bypassCtx), gomock.AssignableToTypeOf(1), gomock.Any()).Return(nil).Times(1)
+
+	mockQuery := mock_cachex.NewMockQuerier(ctrl)
+	mockQuery.EXPECT().Query(gomock.Eq(bypassCtx), gomock.AssignableToTypeOf(1), gomock.Any()).DoAndReturn(func(ctx context.Context, key, value interface{}) error {
+		reflect.ValueOf(value).Elem().Set(reflect.ValueOf(key))
+		return nil
+	}).Times(1)
+
+	c := NewCachex(mockStorage, mockQuery)
+
+	// 跳过缓存读取，不调用存储后端的Get
+	var value int
+	err := c.Get(bypassCtx, 1, &value)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+}
@@ -36,8 +36,27 @@ type Cachex struct {
 	// useStale UseStaleWhenError
 	useStale bool
 
-	deletableStorage   DeletableStorage
-	withTTLableStorage SetWithTTLableStorage
+	// jitter 写入时的TTL抖动策略
+	jitter Jitter
+
+	// degradation 存储后端不可用时的降级策略
+	degradation Degradation
+	breaker     *storageBreaker
+
+	// writeBack 异步回写协程池，为nil时同步回写
+	writeBack *writeBackPool
+
+	// keyFunc key规范化函数，为nil时使用CanonicalKey
+	keyFunc KeyFunc
+
+	deletableStorage        DeletableStorage
+	clearableStorage        ClearableStorage
+	patternDeletableStorage PatternDeletableStorage
+	withTTLableStorage      SetWithTTLableStorage
+	modeWritableStorage     ModeWritableStorage
+	ageableStorage          AgeableStorage
+	casStorage              CASStorage
+	leasableStorage         LeasableStorage
 }
 
 // NewCachex 新建缓存处理对象
@@ -47,7 +66,13 @@ func NewCachex(storage Storage, querier Querier) (c *Cachex) {
 		querier: querier,
 	}
 	c.deletableStorage, _ = storage.(DeletableStorage)
+	c.clearableStorage, _ = storage.(ClearableStorage)
+	c.patternDeletableStorage, _ = storage.(PatternDeletableStorage)
 	c.withTTLableStorage, _ = storage.(SetWithTTLableStorage)
+	c.modeWritableStorage, _ = storage.(ModeWritableStorage)
+	c.ageableStorage, _ = storage.(AgeableStorage)
+	c.casStorage, _ = storage.(CASStorage)
+	c.leasableStorage, _ = storage.(LeasableStorage)
 	return c
 }
 
@@ -55,6 +80,14 @@ func NewCachex(storage Storage, querier Querier) (c *Cachex) {
 type getOptions struct {
 	querier Querier
 	ttl     time.Duration
+
+	// bypass 不读缓存，总是查询，并更新缓存
+	bypass bool
+	// noStore 不读缓存，总是查询，且不更新缓存
+	noStore bool
+	// maxAge 缓存数据的最大年龄，超过视为过期
+	maxAge    time.Duration
+	hasMaxAge bool
 }
 
 // GetOption Get方法的可选参数项结构，不需要直接调用。
@@ -81,6 +114,43 @@ func GetTTLOption(ttl time.Duration) GetOption {
 	}
 }
 
+// GetBypassOption 为Get操作跳过缓存读取，总是查询，查询结果仍更新到存储后端。
+// 不与其它请求合并查询。对应请求头Cache-Control: no-cache。
+func GetBypassOption() GetOption {
+	return GetOption{
+		apply: func(options *getOptions) {
+			options.bypass = true
+		},
+	}
+}
+
+// GetNoStoreOption 为Get操作跳过缓存读取，总是查询，且查询结果不更新到存储后端。
+// 不与其它请求合并查询。对应请求头Cache-Control: no-store。
+func GetNoStoreOption() GetOption {
+	return GetOption{
+		apply: func(options *getOptions) {
+			options.noStore = true
+		},
+	}
+}
+
+// GetMaxAgeOption 为Get操作定制缓存数据的最大年龄，写入时间超过maxAge的缓存数据视为过期。
+// maxAge不大于0时，等同GetBypassOption。
+// 需要存储后端支持（实现AgeableStorage），否则报错；存储后端无法得知数据的年龄时，GetWithAge返回ErrNotSupported。
+// 不与其它请求合并查询。对应请求头Cache-Control: max-age=N。
+func GetMaxAgeOption(maxAge time.Duration) GetOption {
+	return GetOption{
+		apply: func(options *getOptions) {
+			if maxAge <= 0 {
+				options.bypass = true
+				return
+			}
+			options.maxAge = maxAge
+			options.hasMaxAge = true
+		},
+	}
+}
+
 // Get 获取
 func (c *Cachex) Get(ctx context.Context, key, value interface{}, opts ...GetOption) error {
 	if v := reflect.ValueOf(value); v.Kind() != reflect.Ptr || v.IsNil() {
@@ -88,7 +158,11 @@ func (c *Cachex) Get(ctx context.Context, key, value interface{}, opts ...GetOpt
 	}
 
 	// 可选参数
+	// 先应用context携带的参数，再应用直接传入的参数
 	var options getOptions
+	for _, opt := range GetOptionsFromContext(ctx) {
+		opt.apply(&options)
+	}
 	for _, opt := range opts {
 		opt.apply(&options)
 	}
@@ -105,22 +179,34 @@ func (c *Cachex) Get(ctx context.Context, key, value interface{}, opts ...GetOpt
 		}
 		ttl = options.ttl
 	}
+	// 最大年龄
+	if options.hasMaxAge && c.ageableStorage == nil {
+		return ErrNotSupported
+	}
+	// 是否读缓存。存储后端被熔断时不读
+	readable := !options.bypass && !options.noStore && c.storageAvailable()
 
 	// 支持包装结构体的key
 	request := key
-	if keyable, ok := key.(Keyable); ok {
-		key = keyable.CacheKey()
+	key, err := c.cacheKey(key)
+	if err != nil {
+		return err
 	}
 
-	err := c.storage.Get(ctx, key, value)
-	if err == nil {
-		return nil
-	} else if _, ok := err.(NotFound); ok {
-		// 下面查询
-	} else if _, ok := err.(Expired); ok {
-		// 数据已过期，下面查询
-	} else if err != nil {
-		return err
+	// token 最近一次读取得到的租约或版本令牌，用于凭租约或比较并回写
+	var token *casToken
+	if readable {
+		var err error
+		token, err = c.storageGet(ctx, key, value, &options)
+		if err == nil {
+			return nil
+		} else if _, ok := err.(NotFound); ok {
+			// 下面查询
+		} else if _, ok := err.(Expired); ok {
+			// 数据已过期，下面查询
+		} else if err != nil && (err == ErrNotSupported || !c.degradation.ReadErrorAsMiss) {
+			return err
+		}
 	}
 
 	if querier == nil {
@@ -129,39 +215,52 @@ func (c *Cachex) Get(ctx context.Context, key, value interface{}, opts ...GetOpt
 
 	// 在一份实例中
 	// 不同时发起重复的查询请求——解决缓存失效风暴
+	// 跳过缓存、不更新缓存、限定最大年龄的请求不合并：共享的结果可能来自缓存，或超过最大年龄
+	dedup := !options.bypass && !options.noStore && !options.hasMaxAge
 	newSentinel := NewSentinel()
-	actual, loaded := c.sentinels.LoadOrStore(key, newSentinel)
-	sentinel := actual.(*Sentinel)
+	sentinel, loaded := newSentinel, false
+	if dedup {
+		var actual interface{}
+		actual, loaded = c.sentinels.LoadOrStore(key, newSentinel)
+		sentinel = actual.(*Sentinel)
+	}
+	// handedOff 哨兵已移交给异步回写任务，由回写任务在写入完成后解锁
+	var handedOff bool
 	if loaded {
 		newSentinel.Close()
 	} else {
 		// 确保生产者总是能发出通知，并解锁
-		defer c.sentinels.Delete(key)
+		defer func() {
+			if dedup && !handedOff {
+				c.sentinels.Delete(key)
+			}
+		}()
 		defer sentinel.CloseIfUnclose()
 	}
 
 	// 双重检查
 	var staled interface{}
-	err = c.storage.Get(ctx, key, value)
-	if err == nil {
-		if !loaded {
-			// 将结果通知等待的过程
-			sentinel.Done(reflect.ValueOf(value).Elem().Interface(), nil)
-		}
-		return nil
-	} else if err == nil {
-		return nil
-	} else if _, ok := err.(NotFound); ok {
-		// 下面查询
-	} else if _, ok := err.(Expired); ok {
-		// 保存过期数据，如果下面查询失败，且useStale，返回过期数据
-		staled = reflect.ValueOf(value).Elem().Interface()
-	} else if err != nil {
-		if !loaded {
-			// 将错误通知等待的过程
-			sentinel.Done(nil, err)
+	if readable && c.storageAvailable() {
+		var err error
+		token, err = c.storageGet(ctx, key, value, &options)
+		if err == nil {
+			if !loaded {
+				// 将结果通知等待的过程
+				sentinel.Done(reflect.ValueOf(value).Elem().Interface(), nil)
+			}
+			return nil
+		} else if _, ok := err.(NotFound); ok {
+			// 下面查询
+		} else if _, ok := err.(Expired); ok {
+			// 保存过期数据，如果下面查询失败，且useStale，返回过期数据
+			staled = reflect.ValueOf(value).Elem().Interface()
+		} else if err != nil && (err == ErrNotSupported || !c.degradation.ReadErrorAsMiss) {
+			if !loaded {
+				// 将错误通知等待的过程
+				sentinel.Done(nil, err)
+			}
+			return err
 		}
-		return err
 	}
 
 	if !loaded {
@@ -181,14 +280,32 @@ func (c *Cachex) Get(ctx context.Context, key, value interface{}, opts ...GetOpt
 			return err
 		}
 
-		// 更新到存储后端
 		elem := reflect.ValueOf(value).Elem().Interface()
-		if ttl != 0 {
-			err = c.withTTLableStorage.SetWithTTL(ctx, key, elem, ttl)
-		} else {
-			err = c.storage.Set(ctx, key, elem)
+		if options.noStore || !c.storageAvailable() {
+			sentinel.Done(elem, nil)
+			return nil
 		}
 
+		// 异步更新到存储后端
+		// 写入完成前，等待的过程和新的Get从哨兵得到结果，不重复查询
+		if c.writeBack != nil {
+			sentinel.Done(elem, nil)
+			handedOff = c.writeBack.submit(key, func() {
+				// 请求的ctx可能在返回后被取消
+				c.storageSet(context.Background(), key, elem, ttl, token)
+				if dedup {
+					c.sentinels.Delete(key)
+				}
+			})
+			if handedOff {
+				return nil
+			}
+			return c.storageSet(ctx, key, elem, ttl, token)
+		}
+
+		// 更新到存储后端
+		err = c.storageSet(ctx, key, elem, ttl, token)
+
 		sentinel.Done(elem, nil)
 
 		return err
@@ -197,23 +314,141 @@ func (c *Cachex) Get(ctx context.Context, key, value interface{}, opts ...GetOpt
 	return sentinel.Wait(ctx, value)
 }
 
+// casToken 读取时得到的租约或版本令牌
+type casToken struct {
+	// lease 为true时version为租约
+	lease   bool
+	version uint64
+}
+
+// storageGet 从存储后端获取缓存数据。如果定制了最大年龄，超过最大年龄的数据返回Expired错误
+// 存储后端支持租约或CAS且未定制最大年龄时，返回租约或版本令牌，优先使用租约
+// 记录存储后端的访问结果，用于降级
+func (c *Cachex) storageGet(ctx context.Context, key, value interface{}, options *getOptions) (*casToken, error) {
+	var err error
+	var age time.Duration
+	var token *casToken
+	if options.hasMaxAge {
+		age, err = c.ageableStorage.GetWithAge(ctx, key, value)
+	} else if c.leasableStorage != nil {
+		var lease uint64
+		lease, err = c.leasableStorage.GetWithLease(ctx, key, value)
+		token = &casToken{lease: true, version: lease}
+	} else if c.casStorage != nil {
+		var version uint64
+		version, err = c.casStorage.GetWithVersion(ctx, key, value)
+		token = &casToken{version: version}
+	} else {
+		err = c.storage.Get(ctx, key, value)
+	}
+
+	switch err.(type) {
+	case nil, NotFound, Expired:
+		c.storageSucceeded()
+	default:
+		if err == ErrNotSupported {
+			// 存储后端的配置不支持该操作（如无法得知数据的年龄），不是存储后端故障
+			return nil, err
+		}
+		c.storageFailed(ctx, "Get", key, err)
+		return nil, err
+	}
+
+	if err == nil && options.hasMaxAge && age > options.maxAge {
+		return nil, expiredError{}
+	}
+	return token, err
+}
+
+// storageSet 将查询结果更新到存储后端。ttl为0时使用存储后端的默认TTL
+// token不为nil时凭租约或比较并写入，租约已失效、key的版本已变化（如查询期间被删除）则放弃写入
+// 记录存储后端的访问结果，用于降级
+func (c *Cachex) storageSet(ctx context.Context, key, value interface{}, ttl time.Duration, token *casToken) error {
+	var err error
+	jitteredCtx, jitteredTTL := c.jitterTTL(ctx, ttl)
+	if token != nil && token.lease {
+		_, err = c.leasableStorage.SetWithLease(jitteredCtx, key, value, token.version, jitteredTTL)
+	} else if token != nil {
+		_, err = c.casStorage.CompareAndSet(jitteredCtx, key, value, token.version, jitteredTTL)
+	} else if ttl != 0 {
+		err = c.withTTLableStorage.SetWithTTL(jitteredCtx, key, value, jitteredTTL)
+	} else {
+		err = c.storage.Set(ctx, key, value)
+	}
+	if err != nil {
+		c.storageFailed(ctx, "Set", key, err)
+		if c.degradation.IgnoreWriteError {
+			return nil
+		}
+		return err
+	}
+
+	c.storageSucceeded()
+	return nil
+}
+
 // Set 更新
 func (c *Cachex) Set(ctx context.Context, key, value interface{}) error {
-	if keyable, ok := key.(Keyable); ok {
-		key = keyable.CacheKey()
+	key, err := c.cacheKey(key)
+	if err != nil {
+		return err
+	}
+	if c.writeBack != nil {
+		// 排在该key未完成的异步回写之后
+		return c.writeBack.do(key, func() error {
+			return c.storage.Set(ctx, key, value)
+		})
 	}
 	return c.storage.Set(ctx, key, value)
 }
 
 // SetWithTTL 更新，并定制TTL
 func (c *Cachex) SetWithTTL(ctx context.Context, key, value interface{}, TTL time.Duration) error {
-	if c.withTTLableStorage != nil {
-		if keyable, ok := key.(Keyable); ok {
-			key = keyable.CacheKey()
-		}
-		c.withTTLableStorage.SetWithTTL(ctx, key, value, TTL)
+	if c.withTTLableStorage == nil {
+		return ErrNotSupported
+	}
+
+	key, err := c.cacheKey(key)
+	if err != nil {
+		return err
+	}
+	ctx, TTL = c.jitterTTL(ctx, TTL)
+	if c.writeBack != nil {
+		// 排在该key未完成的异步回写之后
+		return c.writeBack.do(key, func() error {
+			return c.withTTLableStorage.SetWithTTL(ctx, key, value, TTL)
+		})
 	}
-	return ErrNotSupported
+	return c.withTTLableStorage.SetWithTTL(ctx, key, value, TTL)
+}
+
+// SetWithMode 按写入模式更新，返回是否写入。
+// 需要存储后端实现ModeWritableStorage接口，否则返回ErrNotSupported
+func (c *Cachex) SetWithMode(ctx context.Context, key, value interface{}, mode WriteMode) (bool, error) {
+	if c.modeWritableStorage == nil {
+		return false, ErrNotSupported
+	}
+
+	key, err := c.cacheKey(key)
+	if err != nil {
+		return false, err
+	}
+	if c.writeBack != nil {
+		// 排在该key未完成的异步回写之后
+		var written bool
+		err = c.writeBack.do(key, func() error {
+			var err error
+			written, err = c.modeWritableStorage.SetWithMode(ctx, key, value, mode)
+			return err
+		})
+		return written, err
+	}
+	return c.modeWritableStorage.SetWithMode(ctx, key, value, mode)
+}
+
+// Add 仅数据不存在时更新，返回是否写入。见SetWithMode
+func (c *Cachex) Add(ctx context.Context, key, value interface{}) (bool, error) {
+	return c.SetWithMode(ctx, key, value, WriteAdd)
 }
 
 // Del 删除
@@ -222,15 +457,131 @@ func (c *Cachex) Del(ctx context.Context, keys ...interface{}) error {
 		return ErrNotSupported
 	}
 
+	var err error
 	for idx, key := range keys {
-		if keyable, ok := key.(Keyable); ok {
-			keys[idx] = keyable.CacheKey()
+		keys[idx], err = c.cacheKey(key)
+		if err != nil {
+			return err
 		}
 	}
+
+	if c.writeBack != nil {
+		// 按回写队列分组，排在各key未完成的异步回写之后，避免删除被迟到的回写覆盖
+		groups := make(map[chan func()][]interface{})
+		var order []chan func()
+		for _, key := range keys {
+			queue := c.writeBack.queueOf(key)
+			if _, exists := groups[queue]; !exists {
+				order = append(order, queue)
+			}
+			groups[queue] = append(groups[queue], key)
+		}
+
+		errs := make([]error, len(order))
+		var wg sync.WaitGroup
+		for idx, queue := range order {
+			group := groups[queue]
+			wg.Add(1)
+			go func(idx int, group []interface{}) {
+				defer wg.Done()
+				errs[idx] = c.writeBack.do(group[0], func() error {
+					return c.deletableStorage.Del(ctx, group...)
+				})
+			}(idx, group)
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
 	return c.deletableStorage.Del(ctx, keys...)
 }
 
+// DelPattern 删除key匹配glob模式的缓存数据，用于批量失效。
+// key先规范化、转为字符串（见KeyString）再匹配，模式语法见MatchPattern。
+// 需要存储后端实现PatternDeletableStorage接口，否则返回ErrNotSupported。
+// 启用了异步回写时，先等待已提交的回写完成。
+func (c *Cachex) DelPattern(ctx context.Context, pattern string) error {
+	if c.patternDeletableStorage == nil {
+		return ErrNotSupported
+	}
+
+	if c.writeBack != nil {
+		c.writeBack.flush()
+	}
+	return c.patternDeletableStorage.DelPattern(ctx, pattern)
+}
+
+// Clear 清空缓存的数据。
+// 需要存储后端实现ClearableStorage接口，否则返回ErrNotSupported。
+// 启用了异步回写时，先等待已提交的回写完成。
+func (c *Cachex) Clear(ctx context.Context) error {
+	if c.clearableStorage == nil {
+		return ErrNotSupported
+	}
+
+	if c.writeBack != nil {
+		c.writeBack.flush()
+	}
+	return c.clearableStorage.Clear(ctx)
+}
+
+// UseAsyncWriteBack 设置查询成功后异步更新存储后端，Get不等待写入完成即返回。默认同步更新。
+// workers为后台回写协程数，queueSize为每个回写协程的队列长度，队列满时Get阻塞等待。
+// 同一个key的回写、Set、SetWithTTL、Del按调用顺序执行。回写出错通过降级策略的OnStorageError上报。
+// 需要调用Close等待未完成的回写。重复调用时，先关闭之前的回写协程池，等待其中未完成的回写。
+func (c *Cachex) UseAsyncWriteBack(workers, queueSize int) {
+	if c.writeBack != nil {
+		c.writeBack.close()
+	}
+	c.writeBack = newWriteBackPool(workers, queueSize)
+}
+
+// Close 关闭，等待未完成的异步回写完成。关闭后回写改为同步进行。
+func (c *Cachex) Close() error {
+	if c.writeBack != nil {
+		c.writeBack.close()
+	}
+	return nil
+}
+
+// cacheKey 返回给存储后端的key。实现了Keyable接口的key先取CacheKey()，再规范化
+func (c *Cachex) cacheKey(key interface{}) (interface{}, error) {
+	if keyable, ok := key.(Keyable); ok {
+		key = keyable.CacheKey()
+	}
+	if c.keyFunc != nil {
+		return c.keyFunc(key)
+	}
+	return CanonicalKey(key)
+}
+
+// UseKeyFunc 设置key规范化函数，存储后端和哨兵均使用规范化后的key。默认使用CanonicalKey。
+func (c *Cachex) UseKeyFunc(keyFunc KeyFunc) {
+	c.keyFunc = keyFunc
+}
+
 // UseStaleWhenError 设置当查询发生错误时，使用过期的缓存数据。该特性需要Storage支持（Get返回过期的缓存数据和Expired错误实现）。默认关闭。
 func (c *Cachex) UseStaleWhenError(use bool) {
 	c.useStale = use
 }
+
+// UseTTLJitter 设置写入时的TTL抖动策略，避免同时写入的大量缓存同时过期。默认不抖动。
+// 只作用于Cachex定制的TTL（GetTTLOption、SetWithTTL）；使用存储后端默认TTL的写入，需要在存储后端上配置抖动。
+// 两者都配置时，抖动过的TTL通过ctx标记传给存储后端，存储后端（通过JitterTTL）不再抖动，幅度不叠加。
+func (c *Cachex) UseTTLJitter(jitter Jitter) {
+	c.jitter = jitter
+}
+
+// jitterTTL 对TTL应用抖动策略。抖动后返回带有标记的ctx，存储后端据此不再抖动
+func (c *Cachex) jitterTTL(ctx context.Context, TTL time.Duration) (context.Context, time.Duration) {
+	if c.jitter == nil || TTL == 0 {
+		return ctx, TTL
+	}
+	return withJitteredTTL(ctx), c.jitter.Jitter(TTL)
+}
@@ -423,3 +423,315 @@ func TestCachexGetTTLOption(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 100, value)
 }
+
+func TestCachexGetBypassAndNoStoreOption(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+
+	notFound := mock_cachex.NewMockNotFound(ctrl)
+	cached := make(map[interface{}]interface{})
+	mockStorage := mock_cachex.NewMockStorage(ctrl)
+	mockStorage.EXPECT().Set(gomock.Eq(ctx), gomock.AssignableToTypeOf(1), gomock.Any()).DoAndReturn(func(ctx context.Context, key, value interface{}) error {
+		cached[key] = value
+		return nil
+	}).AnyTimes()
+	mockStorage.EXPECT().Get(gomock.Eq(ctx), gomock.AssignableToTypeOf(1), gomock.Any()).DoAndReturn(func(ctx context.Context, key, value interface{}) error {
+		v, exist := cached[key]
+		if !exist {
+			return notFound
+		}
+		reflect.ValueOf(value).Elem().Set(reflect.ValueOf(v))
+		return nil
+	}).AnyTimes()
+
+	var queried int
+	mockQuery := mock_cachex.NewMockQuerier(ctrl)
+	mockQuery.EXPECT().Query(gomock.Eq(ctx), gomock.AssignableToTypeOf(1), gomock.Any()).DoAndReturn(func(ctx context.Context, key, value interface{}) error {
+		queried++
+		reflect.ValueOf(value).Elem().Set(reflect.ValueOf(queried))
+		return nil
+	}).AnyTimes()
+
+	c := NewCachex(mockStorage, mockQuery)
+
+	var value int
+	err := c.Get(ctx, 1, &value)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+
+	// 跳过缓存，查询结果更新缓存
+	err = c.Get(ctx, 1, &value, GetBypassOption())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, value)
+	assert.Equal(t, 2, cached[1])
+
+	// 跳过缓存，查询结果不更新缓存
+	err = c.Get(ctx, 1, &value, GetNoStoreOption())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, value)
+	assert.Equal(t, 2, cached[1])
+
+	// 正常读缓存
+	err = c.Get(ctx, 1, &value)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, value)
+}
+
+func TestCachexGetBypassNotShared(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+
+	notFound := mock_cachex.NewMockNotFound(ctrl)
+	mockStorage := mock_cachex.NewMockStorage(ctrl)
+	mockStorage.EXPECT().Get(gomock.Eq(ctx), gomock.Any(), gomock.Any()).Return(notFound).AnyTimes()
+	mockStorage.EXPECT().Set(gomock.Eq(ctx), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var queried int32
+	query := func(ctx context.Context, key, value interface{}) error {
+		n := atomic.AddInt32(&queried, 1)
+		if n == 1 {
+			// 普通请求的查询进行中
+			close(started)
+			<-release
+		}
+		*value.(*int) = int(n)
+		return nil
+	}
+	c := NewCachex(mockStorage, QueryFunc(query))
+
+	done := make(chan int)
+	go func() {
+		var value int
+		err := c.Get(ctx, 1, &value)
+		assert.NoError(t, err)
+		done <- value
+	}()
+	<-started
+
+	// 跳过缓存、不更新缓存、限定最大年龄的请求不等待进行中的查询
+	for _, opt := range []GetOption{GetBypassOption(), GetNoStoreOption()} {
+		var value int
+		err := c.Get(ctx, 1, &value, opt)
+		assert.NoError(t, err)
+		assert.NotEqual(t, 1, value)
+	}
+
+	close(release)
+	assert.Equal(t, 1, <-done)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&queried))
+}
+
+func TestCachexGetMaxAgeOption(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+
+	notFound := mock_cachex.NewMockNotFound(ctrl)
+	cached := make(map[interface{}]interface{})
+	setTimes := make(map[interface{}]time.Time)
+	mockStorage := mock_cachex.NewMockAgeableStorage(ctrl)
+	mockStorage.EXPECT().Set(gomock.Eq(ctx), gomock.AssignableToTypeOf(1), gomock.Any()).DoAndReturn(func(ctx context.Context, key, value interface{}) error {
+		cached[key] = value
+		setTimes[key] = time.Now()
+		return nil
+	}).AnyTimes()
+	mockStorage.EXPECT().GetWithAge(gomock.Eq(ctx), gomock.AssignableToTypeOf(1), gomock.Any()).DoAndReturn(func(ctx context.Context, key, value interface{}) (time.Duration, error) {
+		v, exist := cached[key]
+		if !exist {
+			return 0, notFound
+		}
+		reflect.ValueOf(value).Elem().Set(reflect.ValueOf(v))
+		return time.Since(setTimes[key]), nil
+	}).AnyTimes()
+
+	var queried int
+	mockQuery := mock_cachex.NewMockQuerier(ctrl)
+	mockQuery.EXPECT().Query(gomock.Eq(ctx), gomock.AssignableToTypeOf(1), gomock.Any()).DoAndReturn(func(ctx context.Context, key, value interface{}) error {
+		queried++
+		reflect.ValueOf(value).Elem().Set(reflect.ValueOf(queried))
+		return nil
+	}).AnyTimes()
+
+	c := NewCachex(mockStorage, mockQuery)
+
+	var value int
+	err := c.Get(ctx, 1, &value, GetMaxAgeOption(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+
+	// 未超过最大年龄
+	err = c.Get(ctx, 1, &value, GetMaxAgeOption(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+
+	// 超过最大年龄
+	time.Sleep(time.Millisecond * 10)
+	err = c.Get(ctx, 1, &value, GetMaxAgeOption(time.Millisecond*5))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, value)
+
+	// 存储后端不支持
+	c = NewCachex(mock_cachex.NewMockStorage(ctrl), mockQuery)
+	err = c.Get(ctx, 1, &value, GetMaxAgeOption(time.Minute))
+	assert.Equal(t, ErrNotSupported, err)
+}
+
+func TestCachexDelPattern(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.TODO()
+
+	mockStorage := mock_cachex.NewMockPatternDeletableStorage(ctrl)
+	var written int32
+	mockStorage.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, key, value interface{}) error {
+		time.Sleep(time.Millisecond * 10)
+		atomic.AddInt32(&written, 1)
+		return nil
+	})
+	mockStorage.EXPECT().DelPattern(gomock.Eq(ctx), "user:*").DoAndReturn(func(ctx context.Context, pattern string) error {
+		// 等待已提交的回写完成
+		assert.Equal(t, int32(1), atomic.LoadInt32(&written))
+		return nil
+	})
+
+	c := NewCachex(mockStorage, nil)
+	c.UseAsyncWriteBack(2, 1)
+	defer c.Close()
+	c.writeBack.submit("user:1", func() {
+		c.storageSet(context.Background(), "user:1", 1, 0, nil)
+	})
+
+	err := c.DelPattern(ctx, "user:*")
+	assert.NoError(t, err)
+
+	// 存储后端不支持
+	c = NewCachex(mock_cachex.NewMockStorage(ctrl), nil)
+	err = c.DelPattern(ctx, "user:*")
+	assert.Equal(t, ErrNotSupported, err)
+	err = c.Clear(ctx)
+	assert.Equal(t, ErrNotSupported, err)
+}
+
+// modeWritableStorage 测试用的按写入模式写入的存储后端
+type modeWritableStorage struct {
+	NopStorage
+	values map[interface{}]interface{}
+}
+
+func (s *modeWritableStorage) SetWithMode(ctx context.Context, key, value interface{}, mode WriteMode) (bool, error) {
+	_, exists := s.values[key]
+	if (mode == WriteAdd && exists) || (mode == WriteReplace && !exists) {
+		return false, nil
+	}
+	s.values[key] = value
+	return true, nil
+}
+
+func TestCachexSetWithMode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.TODO()
+
+	storage := &modeWritableStorage{values: make(map[interface{}]interface{})}
+	c := NewCachex(storage, nil)
+
+	written, err := c.SetWithMode(ctx, 1, 1, WriteReplace)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	written, err = c.Add(ctx, 1, 1)
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	written, err = c.Add(ctx, 1, 2)
+	assert.NoError(t, err)
+	assert.False(t, written)
+	assert.Equal(t, 1, storage.values[1])
+
+	written, err = c.SetWithMode(ctx, 1, 3, WriteReplace)
+	assert.NoError(t, err)
+	assert.True(t, written)
+	assert.Equal(t, 3, storage.values[1])
+
+	// 异步回写时，排在回写之后
+	c.UseAsyncWriteBack(1, 1)
+	written, err = c.SetWithMode(ctx, []int{1, 2}, 4, WriteOverwrite)
+	assert.NoError(t, err)
+	assert.True(t, written)
+	canonical, _ := CanonicalKey([]int{1, 2})
+	assert.Equal(t, 4, storage.values[canonical])
+	c.Close()
+
+	// 存储后端不支持
+	c = NewCachex(mock_cachex.NewMockStorage(ctrl), nil)
+	_, err = c.Add(ctx, 1, 1)
+	assert.Equal(t, ErrNotSupported, err)
+}
+
+func TestCachexCompareAndSet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.TODO()
+
+	notFound := mock_cachex.NewMockNotFound(ctrl)
+	notFound.EXPECT().Error().Return("not found").AnyTimes()
+
+	mockStorage := mock_cachex.NewMockCASStorage(ctrl)
+	// 第一次读取和双重检查读取
+	mockStorage.EXPECT().GetWithVersion(gomock.Eq(ctx), 1, gomock.Any()).Return(uint64(1), notFound)
+	mockStorage.EXPECT().GetWithVersion(gomock.Eq(ctx), 1, gomock.Any()).Return(uint64(2), notFound)
+	// 查询期间被删除，版本已变化，放弃写入
+	mockStorage.EXPECT().CompareAndSet(gomock.Eq(ctx), 1, 1, uint64(2), time.Duration(0)).Return(false, nil)
+
+	mockQuery := mock_cachex.NewMockQuerier(ctrl)
+	mockQuery.EXPECT().Query(gomock.Eq(ctx), 1, gomock.Any()).DoAndReturn(func(ctx context.Context, key, value interface{}) error {
+		reflect.ValueOf(value).Elem().Set(reflect.ValueOf(1))
+		return nil
+	})
+
+	c := NewCachex(mockStorage, mockQuery)
+
+	var value int
+	err := c.Get(ctx, 1, &value)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+}
+
+func TestCachexLease(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.TODO()
+
+	notFound := mock_cachex.NewMockNotFound(ctrl)
+	notFound.EXPECT().Error().Return("not found").AnyTimes()
+
+	mockStorage := mock_cachex.NewMockLeasableStorage(ctrl)
+	// 第一次读取和双重检查读取，没找到，发放租约
+	mockStorage.EXPECT().GetWithLease(gomock.Eq(ctx), 1, gomock.Any()).Return(uint64(7), notFound).Times(2)
+	// 查询期间被删除，租约已失效，放弃写入
+	mockStorage.EXPECT().SetWithLease(gomock.Eq(ctx), 1, 1, uint64(7), time.Duration(0)).Return(false, nil)
+
+	mockQuery := mock_cachex.NewMockQuerier(ctrl)
+	mockQuery.EXPECT().Query(gomock.Eq(ctx), 1, gomock.Any()).DoAndReturn(func(ctx context.Context, key, value interface{}) error {
+		reflect.ValueOf(value).Elem().Set(reflect.ValueOf(1))
+		return nil
+	})
+
+	c := NewCachex(mockStorage, mockQuery)
+
+	var value int
+	err := c.Get(ctx, 1, &value)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+}
@@ -0,0 +1,60 @@
+/*
+ * 数据编解码
+ * 序列化缓存数据的存储后端（rdscache、memcache、boltcache、sqlcache、peercache）共用的编解码接口
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package cachex
+
+import (
+	"encoding/json"
+
+	"github.com/vmihailenco/msgpack"
+)
+
+// Codec 数据编解码接口
+type Codec interface {
+	// Marshal 编码
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal 解码。v必须是非nil指针
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// MsgpackCodec msgpack编解码器，存储后端默认的编解码器
+type MsgpackCodec struct{}
+
+// Marshal 实现Codec接口
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+// Unmarshal 实现Codec接口
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// ContentType 返回编码数据的MIME类型，用于HTTP传输（如peercache）
+func (MsgpackCodec) ContentType() string {
+	return "application/msgpack"
+}
+
+// JSONCodec json编解码器
+type JSONCodec struct{}
+
+// Marshal 实现Codec接口
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal 实现Codec接口
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// ContentType 返回编码数据的MIME类型，用于HTTP传输（如peercache）
+func (JSONCodec) ContentType() string {
+	return "application/json"
+}
@@ -0,0 +1,105 @@
+/*
+ * 存储后端不可用时的降级
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package cachex
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Degradation 存储后端不可用时的降级策略
+type Degradation struct {
+	// ReadErrorAsMiss 从存储后端读取出错时，视为未命中，继续查询
+	ReadErrorAsMiss bool
+
+	// IgnoreWriteError 查询成功后更新存储后端出错时，不返回错误
+	IgnoreWriteError bool
+
+	// OnStorageError 存储后端出错时的回调，可用于上报。op为出错的存储后端操作名，如Get、Set
+	OnStorageError func(ctx context.Context, op string, key interface{}, err error)
+
+	// FailureThreshold 存储后端连续出错达到该次数后，在BypassDuration内绕过存储后端（不读也不写）。0表示不绕过
+	FailureThreshold int
+
+	// BypassDuration 绕过存储后端的时长。到期后恢复访问存储后端，再次出错将继续绕过
+	BypassDuration time.Duration
+}
+
+// storageBreaker 存储后端熔断器。连续出错达到阈值后，暂时绕过存储后端
+type storageBreaker struct {
+	threshold int64
+	duration  time.Duration
+
+	// failures 连续出错次数
+	failures int64
+	// openUntil 绕过存储后端直到该时间（UnixNano）
+	openUntil int64
+}
+
+// allow 是否允许访问存储后端
+func (b *storageBreaker) allow() bool {
+	until := atomic.LoadInt64(&b.openUntil)
+	return until == 0 || time.Now().UnixNano() >= until
+}
+
+// success 记录一次成功的访问
+func (b *storageBreaker) success() {
+	if atomic.LoadInt64(&b.failures) != 0 {
+		atomic.StoreInt64(&b.failures, 0)
+	}
+	if atomic.LoadInt64(&b.openUntil) != 0 {
+		atomic.StoreInt64(&b.openUntil, 0)
+	}
+}
+
+// failure 记录一次出错的访问
+func (b *storageBreaker) failure() {
+	if b.threshold <= 0 {
+		return
+	}
+	// 不清零失败计数，绕过到期后再次出错将立即继续绕过
+	if atomic.AddInt64(&b.failures, 1) >= b.threshold {
+		atomic.StoreInt64(&b.openUntil, time.Now().Add(b.duration).UnixNano())
+	}
+}
+
+// UseDegradation 设置存储后端不可用时的降级策略，避免缓存故障演变为整体故障。默认不降级。
+// 只作用于Get；Set、SetWithTTL、Del仍直接返回存储后端的错误。
+func (c *Cachex) UseDegradation(degradation Degradation) {
+	c.degradation = degradation
+	c.breaker = &storageBreaker{
+		threshold: int64(degradation.FailureThreshold),
+		duration:  degradation.BypassDuration,
+	}
+}
+
+// storageAvailable 存储后端当前是否可访问
+func (c *Cachex) storageAvailable() bool {
+	return c.breaker == nil || c.breaker.allow()
+}
+
+// storageSucceeded 记录存储后端访问成功
+func (c *Cachex) storageSucceeded() {
+	if c.breaker != nil {
+		c.breaker.success()
+	}
+}
+
+// storageFailed 记录存储后端访问出错。调用方取消或超时导致的出错不是存储后端故障，不记录
+func (c *Cachex) storageFailed(ctx context.Context, op string, key interface{}, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	if c.breaker != nil {
+		c.breaker.failure()
+	}
+	if c.degradation.OnStorageError != nil {
+		c.degradation.OnStorageError(ctx, op, key, err)
+	}
+}
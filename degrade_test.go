@@ -0,0 +1,124 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex/mock_cachex"
+)
+
+func TestCachexUseDegradation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+
+	storageGetErr := errors.New("storage get error")
+	storageSetErr := errors.New("storage set error")
+
+	var getTimes, setTimes int
+	mockStorage := mock_cachex.NewMockStorage(ctrl)
+	mockStorage.EXPECT().Get(gomock.Eq(ctx), gomock.AssignableToTypeOf(1), gomock.Any()).DoAndReturn(func(ctx context.Context, key, value interface{}) error {
+		getTimes++
+		return storageGetErr
+	}).AnyTimes()
+	mockStorage.EXPECT().Set(gomock.Eq(ctx), gomock.AssignableToTypeOf(1), gomock.Any()).DoAndReturn(func(ctx context.Context, key, value interface{}) error {
+		setTimes++
+		return storageSetErr
+	}).AnyTimes()
+
+	mockQuery := mock_cachex.NewMockQuerier(ctrl)
+	mockQuery.EXPECT().Query(gomock.Eq(ctx), gomock.AssignableToTypeOf(1), gomock.Any()).DoAndReturn(func(ctx context.Context, key, value interface{}) error {
+		reflect.ValueOf(value).Elem().Set(reflect.ValueOf(key))
+		return nil
+	}).AnyTimes()
+
+	c := NewCachex(mockStorage, mockQuery)
+
+	// 默认不降级
+	var value int
+	err := c.Get(ctx, 1, &value)
+	assert.Equal(t, storageGetErr, err)
+
+	var reported []error
+	c.UseDegradation(Degradation{
+		ReadErrorAsMiss:  true,
+		IgnoreWriteError: true,
+		OnStorageError: func(ctx context.Context, op string, key interface{}, err error) {
+			reported = append(reported, err)
+		},
+		FailureThreshold: 3,
+		BypassDuration:   time.Millisecond * 50,
+	})
+
+	// 读写出错均不影响查询结果
+	err = c.Get(ctx, 1, &value)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, []error{storageGetErr, storageGetErr, storageSetErr}, reported)
+
+	// 连续出错达到阈值，绕过存储后端
+	getTimes, setTimes = 0, 0
+	err = c.Get(ctx, 2, &value)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, value)
+	assert.Equal(t, 0, getTimes)
+	assert.Equal(t, 0, setTimes)
+
+	// 到期后恢复访问存储后端
+	time.Sleep(time.Millisecond * 60)
+	err = c.Get(ctx, 3, &value)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, value)
+	assert.Equal(t, 1, getTimes)
+}
+
+func TestCachexUseDegradationCanceled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var getTimes int
+	mockStorage := mock_cachex.NewMockStorage(ctrl)
+	mockStorage.EXPECT().Get(gomock.Any(), gomock.AssignableToTypeOf(1), gomock.Any()).DoAndReturn(func(ctx context.Context, key, value interface{}) error {
+		getTimes++
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		reflect.ValueOf(value).Elem().Set(reflect.ValueOf(key))
+		return nil
+	}).AnyTimes()
+
+	c := NewCachex(mockStorage, nil)
+	var reported []error
+	c.UseDegradation(Degradation{
+		OnStorageError: func(ctx context.Context, op string, key interface{}, err error) {
+			reported = append(reported, err)
+		},
+		FailureThreshold: 1,
+		BypassDuration:   time.Minute,
+	})
+
+	// 调用方取消、超时不计为存储后端出错
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel2 := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel2()
+	var value int
+	for _, ctx := range []context.Context{canceled, expired, canceled} {
+		err := c.Get(ctx, 1, &value)
+		assert.Error(t, err)
+	}
+	assert.Empty(t, reported)
+
+	// 没有绕过存储后端
+	getTimes = 0
+	err := c.Get(context.Background(), 1, &value)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, 1, getTimes)
+}
@@ -19,3 +19,14 @@ type NotFound interface {
 	error
 	NotFound()
 }
+
+// expiredError 数据已过期错误，实现Expired接口
+type expiredError struct{}
+
+// Error 实现error接口
+func (expiredError) Error() string {
+	return "expired"
+}
+
+// Expired 实现Expired错误接口
+func (expiredError) Expired() {}
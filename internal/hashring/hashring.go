@@ -0,0 +1,71 @@
+/*
+ * 一致性哈希环
+ * memcache选择服务器、peercache选择节点共用
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package hashring
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// DefaultReplicas 每个节点默认的虚拟节点数
+const DefaultReplicas = 160
+
+// Ring 一致性哈希环。创建后只读，并发安全。
+// 增删节点时，只有少部分key改变所属的节点。
+type Ring struct {
+	// hashes 有序的虚拟节点哈希值
+	hashes []uint32
+
+	// nodes 虚拟节点哈希值到节点的映射
+	nodes map[uint32]string
+}
+
+// New 新建一致性哈希环。replicas为每个节点的虚拟节点数，不大于0时使用DefaultReplicas。
+// 虚拟节点哈希值冲突时，保留先加入的节点
+func New(replicas int, nodes ...string) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+
+	ring := &Ring{
+		hashes: make([]uint32, 0, len(nodes)*replicas),
+		nodes:  make(map[uint32]string, len(nodes)*replicas),
+	}
+	for _, node := range nodes {
+		for i := 0; i < replicas; i++ {
+			hash := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + node))
+			if _, ok := ring.nodes[hash]; ok {
+				continue
+			}
+			ring.hashes = append(ring.hashes, hash)
+			ring.nodes[hash] = node
+		}
+	}
+	sort.Slice(ring.hashes, func(i, j int) bool {
+		return ring.hashes[i] < ring.hashes[j]
+	})
+	return ring
+}
+
+// Pick 返回key所属的节点。没有节点时ok返回false
+func (r *Ring) Pick(key string) (node string, ok bool) {
+	if len(r.hashes) == 0 {
+		return "", false
+	}
+
+	hash := crc32.ChecksumIEEE([]byte(key))
+	idx := sort.Search(len(r.hashes), func(i int) bool {
+		return r.hashes[i] >= hash
+	})
+	if idx == len(r.hashes) {
+		idx = 0
+	}
+	return r.nodes[r.hashes[idx]], true
+}
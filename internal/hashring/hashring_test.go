@@ -0,0 +1,46 @@
+package hashring
+
+// wencan
+// 2026-10-19
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRing(t *testing.T) {
+	ring := New(0, "a", "b", "c")
+	assert.Len(t, ring.hashes, 3*DefaultReplicas)
+
+	picked := make(map[string]string)
+	owned := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		node, ok := ring.Pick(key)
+		assert.True(t, ok)
+		picked[key] = node
+		owned[node]++
+	}
+	assert.Len(t, owned, 3)
+
+	// 增加节点，只有少部分key改变所属的节点
+	ring = New(0, "a", "b", "c", "d")
+	moved := 0
+	for key, node := range picked {
+		again, _ := ring.Pick(key)
+		if again != node {
+			assert.Equal(t, "d", again)
+			moved++
+		}
+	}
+	assert.True(t, moved > 0 && moved < 500, "moved: %d", moved)
+
+	// 重复的节点不产生重复的虚拟节点
+	ring = New(10, "a", "a")
+	assert.Len(t, ring.hashes, 10)
+
+	_, ok := New(0).Pick("key")
+	assert.False(t, ok)
+}
@@ -0,0 +1,101 @@
+/*
+ * TTL抖动
+ * 用于避免大量缓存数据同时过期
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package cachex
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Jitter TTL抖动策略接口
+type Jitter interface {
+	// Jitter 返回抖动后的TTL。TTL为0（不过期）时应原样返回
+	Jitter(TTL time.Duration) time.Duration
+}
+
+// JitterFunc 抖动函数签名。可用于实现自定义的分布
+type JitterFunc func(TTL time.Duration) time.Duration
+
+// Jitter 抖动函数实现Jitter接口
+func (fun JitterFunc) Jitter(TTL time.Duration) time.Duration {
+	return fun(TTL)
+}
+
+// jitteredContextKey context中标记写入的TTL已被Cachex抖动
+type jitteredContextKey struct{}
+
+// withJitteredTTL 返回标记写入的TTL已被抖动的context
+func withJitteredTTL(ctx context.Context) context.Context {
+	return context.WithValue(ctx, jitteredContextKey{}, true)
+}
+
+// JitterTTL 对写入的TTL应用抖动策略，供存储后端使用。
+// jitter为nil、TTL为0（不过期），或ctx标记TTL已被Cachex抖动（见Cachex.UseTTLJitter）时原样返回，避免两层抖动叠加
+func JitterTTL(ctx context.Context, jitter Jitter, TTL time.Duration) time.Duration {
+	if jitter == nil || TTL == 0 {
+		return TTL
+	}
+	if jittered, _ := ctx.Value(jitteredContextKey{}).(bool); jittered {
+		return TTL
+	}
+	return jitter.Jitter(TTL)
+}
+
+// lockedRand 并发安全的随机数生成器
+type lockedRand struct {
+	lock sync.Mutex
+	rnd  *rand.Rand
+}
+
+func (r *lockedRand) Float64() float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.rnd.Float64()
+}
+
+// percentJitter 按百分比均匀抖动
+type percentJitter struct {
+	percent float64
+	rnd     *lockedRand
+}
+
+// NewPercentJitter 新建按百分比抖动的策略。
+// 抖动后的TTL在[TTL*(1-percent), TTL*(1+percent)]内均匀分布，percent取值[0, 1)，为0时不抖动，超出范围panic。
+// Cachex和存储后端都配置了抖动时，Cachex抖动过的TTL存储后端不再抖动，见Cachex.UseTTLJitter。
+// src为随机源，可注入固定种子的随机源以便测试；为nil时使用以当前时间为种子的随机源。
+func NewPercentJitter(percent float64, src rand.Source) Jitter {
+	if percent < 0 || percent >= 1 {
+		panic("percent must be in [0, 1)")
+	}
+	if src == nil {
+		src = rand.NewSource(time.Now().UnixNano())
+	}
+	return &percentJitter{
+		percent: percent,
+		rnd:     &lockedRand{rnd: rand.New(src)},
+	}
+}
+
+// Jitter 实现Jitter接口
+func (j *percentJitter) Jitter(TTL time.Duration) time.Duration {
+	if TTL <= 0 || j.percent == 0 {
+		return TTL
+	}
+
+	// [-1, 1)
+	factor := j.rnd.Float64()*2 - 1
+	jittered := TTL + time.Duration(float64(TTL)*j.percent*factor)
+	if jittered <= 0 {
+		// 不能让抖动变成“不过期”
+		jittered = 1
+	}
+	return jittered
+}
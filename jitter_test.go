@@ -0,0 +1,76 @@
+package cachex
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex/mock_cachex"
+)
+
+func TestPercentJitter(t *testing.T) {
+	ttl := time.Minute
+
+	jitter := NewPercentJitter(0.1, rand.NewSource(1))
+	another := NewPercentJitter(0.1, rand.NewSource(1))
+
+	var different bool
+	for i := 0; i < 100; i++ {
+		jittered := jitter.Jitter(ttl)
+		assert.True(t, jittered >= ttl*9/10)
+		assert.True(t, jittered <= ttl*11/10)
+		if jittered != ttl {
+			different = true
+		}
+
+		// 相同的随机源，结果确定
+		assert.Equal(t, jittered, another.Jitter(ttl))
+	}
+	assert.True(t, different)
+
+	// 不过期的数据不抖动
+	assert.Equal(t, time.Duration(0), jitter.Jitter(0))
+
+	// percent为0时不抖动，超出[0, 1)时panic
+	assert.Equal(t, ttl, NewPercentJitter(0, nil).Jitter(ttl))
+	assert.Panics(t, func() { NewPercentJitter(1, nil) })
+	assert.Panics(t, func() { NewPercentJitter(-0.1, nil) })
+}
+
+func TestCachexUseTTLJitter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+
+	// 存储后端同样配置了抖动
+	storageJitter := JitterFunc(func(TTL time.Duration) time.Duration {
+		return TTL + time.Hour
+	})
+	var ttls []time.Duration
+	mockStorage := mock_cachex.NewMockSetWithTTLableStorage(ctrl)
+	mockStorage.EXPECT().SetWithTTL(gomock.Any(), gomock.AssignableToTypeOf(1), gomock.Any(), gomock.AssignableToTypeOf(time.Minute)).DoAndReturn(func(ctx context.Context, key, value interface{}, ttl time.Duration) error {
+		ttls = append(ttls, JitterTTL(ctx, storageJitter, ttl))
+		return nil
+	}).AnyTimes()
+
+	c := NewCachex(mockStorage, nil)
+	c.UseTTLJitter(JitterFunc(func(TTL time.Duration) time.Duration {
+		return TTL + time.Second
+	}))
+
+	// 存储后端不再抖动Cachex抖动过的TTL
+	err := c.SetWithTTL(ctx, 1, 1, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Duration{time.Minute + time.Second}, ttls)
+
+	// Cachex未配置抖动时，由存储后端抖动
+	c.UseTTLJitter(nil)
+	err = c.SetWithTTL(ctx, 1, 1, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute+time.Hour, ttls[1])
+	assert.Equal(t, time.Duration(0), JitterTTL(ctx, storageJitter, 0))
+}
@@ -0,0 +1,232 @@
+/*
+ * key规范化
+ * 将结构体、切片、map等key转为确定的、可作为map key的值
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package cachex
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedKey key类型不支持
+var ErrUnsupportedKey = errors.New("key type is unacceptable")
+
+// KeyFunc key规范化函数签名。返回的key必须可以作为map key，相等的原始key必须返回相等的key
+type KeyFunc func(key interface{}) (interface{}, error)
+
+// encodedKey 不可作为map key的key规范化后的值。带上原始类型，不会与字符串等其它类型的key相等
+type encodedKey struct {
+	typ reflect.Type
+	key string
+}
+
+// String 实现fmt.Stringer，返回编码后的字符串（见KeyString）
+func (k encodedKey) String() string {
+	return k.key
+}
+
+// CanonicalKey 默认的key规范化函数。
+// 实现了Keyable接口的key先取CacheKey()。
+// 可以作为map key的key原样返回；
+// []byte、包含切片、map的结构体、切片、map等不可作为map key的key，编码为确定的字符串（见KeyString），
+// 返回带有原始类型的内部类型的值，与相同内容的字符串key不相等。
+func CanonicalKey(key interface{}) (interface{}, error) {
+	if keyable, ok := key.(Keyable); ok {
+		key = keyable.CacheKey()
+	}
+
+	if b, ok := key.([]byte); ok {
+		return encodedKey{typ: reflect.TypeOf(key), key: string(b)}, nil
+	}
+	if hashable(reflect.ValueOf(key)) {
+		return key, nil
+	}
+	skey, err := KeyString(key)
+	if err != nil {
+		return nil, err
+	}
+	return encodedKey{typ: reflect.TypeOf(key), key: skey}, nil
+}
+
+// KeyString 将key转为确定的字符串。
+// 实现了Keyable接口的key先取CacheKey()。
+// 实现了fmt.Stringer的key返回String()；布尔、数字、字符串类型的key返回fmt.Sprint的结果；[]byte返回对应的字符串；
+// 结构体、切片、数组、map、指针编码为确定的字符串，结构体、切片、数组、map带类型名（与字符串key区分），map按编码后的key排序。
+// 包含chan、func等的key返回ErrUnsupportedKey。
+func KeyString(key interface{}) (string, error) {
+	if keyable, ok := key.(Keyable); ok {
+		key = keyable.CacheKey()
+	}
+
+	switch t := key.(type) {
+	case fmt.Stringer:
+		return t.String(), nil
+	case string:
+		return t, nil
+	case []byte:
+		return string(t), nil
+	}
+
+	v := reflect.ValueOf(key)
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.String:
+		return fmt.Sprint(key), nil
+	}
+
+	var builder strings.Builder
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		// 结构体编码时已带类型名
+		builder.WriteString(v.Type().String())
+	}
+	err := encodeKey(&builder, v)
+	if err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// KeyStringFunc 用key规范化函数规范化key（keyFunc为nil时不规范化）后，转为确定的字符串（见KeyString）。
+// 以字符串为key的存储后端使用
+func KeyStringFunc(keyFunc KeyFunc, key interface{}) (string, error) {
+	if keyFunc != nil {
+		if keyable, ok := key.(Keyable); ok {
+			key = keyable.CacheKey()
+		}
+		var err error
+		key, err = keyFunc(key)
+		if err != nil {
+			return "", err
+		}
+	}
+	return KeyString(key)
+}
+
+// hashable 值是否可以作为map key
+func hashable(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Invalid:
+		// nil
+		return true
+	case reflect.Slice, reflect.Map, reflect.Func:
+		return false
+	case reflect.Interface:
+		if v.IsNil() {
+			return true
+		}
+		return hashable(v.Elem())
+	case reflect.Array:
+		for idx := 0; idx < v.Len(); idx++ {
+			if !hashable(v.Index(idx)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Struct:
+		for idx := 0; idx < v.NumField(); idx++ {
+			if !hashable(v.Field(idx)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return true
+	}
+}
+
+// encodeKey 将key编码为确定的字符串
+func encodeKey(builder *strings.Builder, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Invalid:
+		builder.WriteString("nil")
+	case reflect.Bool:
+		builder.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		builder.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		builder.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		builder.WriteString(strconv.FormatFloat(v.Float(), 'g', -1, 64))
+	case reflect.Complex64, reflect.Complex128:
+		builder.WriteString(strconv.FormatComplex(v.Complex(), 'g', -1, 128))
+	case reflect.String:
+		builder.WriteString(strconv.Quote(v.String()))
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			builder.WriteString("nil")
+			return nil
+		}
+		return encodeKey(builder, v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			builder.WriteString(strconv.Quote(string(v.Bytes())))
+			return nil
+		}
+		builder.WriteByte('[')
+		for idx := 0; idx < v.Len(); idx++ {
+			if idx > 0 {
+				builder.WriteByte(',')
+			}
+			err := encodeKey(builder, v.Index(idx))
+			if err != nil {
+				return err
+			}
+		}
+		builder.WriteByte(']')
+	case reflect.Map:
+		// 按编码后的key排序，保证结果确定
+		entries := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			var entry strings.Builder
+			err := encodeKey(&entry, iter.Key())
+			if err != nil {
+				return err
+			}
+			entry.WriteByte(':')
+			err = encodeKey(&entry, iter.Value())
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry.String())
+		}
+		sort.Strings(entries)
+
+		builder.WriteByte('{')
+		builder.WriteString(strings.Join(entries, ","))
+		builder.WriteByte('}')
+	case reflect.Struct:
+		// 带上类型名，区分字段相同的不同结构体
+		builder.WriteString(v.Type().String())
+		builder.WriteByte('{')
+		for idx := 0; idx < v.NumField(); idx++ {
+			if idx > 0 {
+				builder.WriteByte(',')
+			}
+			builder.WriteString(v.Type().Field(idx).Name)
+			builder.WriteByte(':')
+			err := encodeKey(builder, v.Field(idx))
+			if err != nil {
+				return err
+			}
+		}
+		builder.WriteByte('}')
+	default:
+		return ErrUnsupportedKey
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCompositeKey struct {
+	IDs    []int
+	Labels map[string]string
+}
+
+func TestCanonicalKey(t *testing.T) {
+	// 可作为map key的原样返回
+	for _, key := range []interface{}{nil, 1, "1", true, 1.5, struct{ A int }{1}, errors.New("test")} {
+		canonical, err := CanonicalKey(key)
+		assert.NoError(t, err)
+		assert.Equal(t, key, canonical)
+	}
+
+	// 不可作为map key的编码为确定的字符串
+	key := testCompositeKey{
+		IDs:    []int{1, 2, 3},
+		Labels: map[string]string{"b": "2", "a": "1", "c": "3"},
+	}
+	canonical, err := CanonicalKey(key)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `cachex.testCompositeKey{IDs:[1,2,3],Labels:{"a":"1","b":"2","c":"3"}}`, canonical.(fmt.Stringer).String())
+		for i := 0; i < 10; i++ {
+			again, _ := CanonicalKey(testCompositeKey{
+				IDs:    []int{1, 2, 3},
+				Labels: map[string]string{"c": "3", "a": "1", "b": "2"},
+			})
+			assert.Equal(t, canonical, again)
+		}
+	}
+
+	canonical, err = CanonicalKey([]byte("bytes"))
+	assert.NoError(t, err)
+	assert.Equal(t, "bytes", canonical.(fmt.Stringer).String())
+
+	// 不与相同内容的字符串key相等
+	for _, pair := range [][2]interface{}{
+		{[]byte("a"), "a"},
+		{[]int{1, 2}, "[1,2]"},
+		{[]int{1, 2}, "[]int[1,2]"},
+		{[]int{1, 2}, []int64{1, 2}},
+	} {
+		canonical, err = CanonicalKey(pair[0])
+		assert.NoError(t, err)
+		other, _ := CanonicalKey(pair[1])
+		assert.NotEqual(t, other, canonical)
+	}
+
+	// Keyable
+	canonical, err = CanonicalKey(&testRequest{num: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 10, canonical)
+
+	_, err = CanonicalKey([]func(){func() {}})
+	assert.Equal(t, ErrUnsupportedKey, err)
+}
+
+func TestKeyString(t *testing.T) {
+	skey, err := KeyString(10)
+	assert.NoError(t, err)
+	assert.Equal(t, "10", skey)
+
+	skey, err = KeyString(struct {
+		A int
+		B *string
+	}{A: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, "struct { A int; B *string }{A:1,B:nil}", skey)
+
+	// 切片、map带类型名，与字符串key区分
+	skey, err = KeyString([]int{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, "[]int[1,2]", skey)
+	skey, err = KeyString(&map[string]int{"a": 1})
+	assert.NoError(t, err)
+	assert.Equal(t, `map[string]int{"a":1}`, skey)
+
+	_, err = KeyString(make(chan int))
+	assert.Equal(t, ErrUnsupportedKey, err)
+
+	// 先规范化
+	lower := func(key interface{}) (interface{}, error) {
+		return strings.ToLower(key.(string)), nil
+	}
+	skey, err = KeyStringFunc(lower, "KEY")
+	assert.NoError(t, err)
+	assert.Equal(t, "key", skey)
+	skey, err = KeyStringFunc(nil, "KEY")
+	assert.NoError(t, err)
+	assert.Equal(t, "KEY", skey)
+}
+
+func TestCachexGetWithCompositeKey(t *testing.T) {
+	ctx := context.Background()
+
+	var queried int
+	query := QueryFunc(func(ctx context.Context, request, value interface{}) error {
+		queried++
+		var sum int
+		for _, num := range request.([]int) {
+			sum += num
+		}
+		reflect.ValueOf(value).Elem().Set(reflect.ValueOf(sum))
+		return nil
+	})
+
+	c := NewCachex(NopStorage{}, query)
+
+	// 切片key不再panic，查询过程得到原始key
+	var value int
+	err := c.Get(ctx, []int{1, 2, 3}, &value)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, value)
+	assert.Equal(t, 1, queried)
+
+	// 自定义规范化函数
+	c.UseKeyFunc(func(key interface{}) (interface{}, error) {
+		return nil, ErrUnsupportedKey
+	})
+	err = c.Get(ctx, []int{1, 2, 3}, &value)
+	assert.Equal(t, ErrUnsupportedKey, err)
+}
@@ -0,0 +1,135 @@
+/*
+ * ARC（Adaptive Replacement Cache）淘汰策略
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package lrucache
+
+// arcPolicy ARC淘汰策略。
+// t1保存只访问过一次的key，t2保存访问过多次的key；b1、b2为从t1、t2淘汰的key（幽灵，只有key没有数据）。
+// 幽灵命中时调整t1的目标长度p：b1命中说明近期性更重要，增大p；b2命中说明频率更重要，减小p
+type arcPolicy struct {
+	capacity int
+
+	// p t1的目标长度
+	p int
+
+	t1, t2 *keyList
+	b1, b2 *keyList
+}
+
+// NewARCPolicy 新建ARC淘汰策略，在近期性（LRU）和频率（LFU）之间自适应。capacity为0时按1处理
+func NewARCPolicy(capacity int) EvictionPolicy {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &arcPolicy{
+		capacity: capacity,
+		t1:       newKeyList(),
+		t2:       newKeyList(),
+		b1:       newKeyList(),
+		b2:       newKeyList(),
+	}
+}
+
+// Add 实现EvictionPolicy接口
+func (p *arcPolicy) Add(key interface{}) {
+	if p.t1.Contains(key) || p.t2.Contains(key) {
+		p.Access(key)
+		return
+	}
+
+	switch {
+	case p.b1.Contains(key):
+		delta := 1
+		if p.b1.Len() < p.b2.Len() {
+			delta = p.b2.Len() / p.b1.Len()
+		}
+		p.p = minInt(p.p+delta, p.capacity)
+		p.b1.Remove(key)
+		p.t2.PushFront(key)
+	case p.b2.Contains(key):
+		delta := 1
+		if p.b2.Len() < p.b1.Len() {
+			delta = p.b1.Len() / p.b2.Len()
+		}
+		p.p = maxInt(p.p-delta, 0)
+		p.b2.Remove(key)
+		p.t2.PushFront(key)
+	default:
+		p.t1.PushFront(key)
+	}
+}
+
+// Access 实现EvictionPolicy接口，升入t2
+func (p *arcPolicy) Access(key interface{}) {
+	if p.t1.Remove(key) {
+		p.t2.PushFront(key)
+		return
+	}
+	p.t2.MoveToFront(key)
+}
+
+// Demote 实现EvictionPolicy接口，移到所在链表的队尾
+func (p *arcPolicy) Demote(key interface{}) {
+	p.t1.MoveToBack(key)
+	p.t2.MoveToBack(key)
+}
+
+// Evict 实现EvictionPolicy接口。t1超过目标长度时从t1淘汰，否则从t2淘汰，淘汰的key进入对应的幽灵链表
+func (p *arcPolicy) Evict() (interface{}, bool) {
+	if p.t1.Len() > 0 && (p.t1.Len() > p.p || p.t2.Len() == 0) {
+		key, _ := p.t1.PopBack()
+		p.b1.PushFront(key)
+		p.trimGhosts()
+		return key, true
+	}
+	if key, ok := p.t2.PopBack(); ok {
+		p.b2.PushFront(key)
+		p.trimGhosts()
+		return key, true
+	}
+	return nil, false
+}
+
+// trimGhosts 限制幽灵链表的长度：t1+b1不超过容量，全部不超过两倍容量
+func (p *arcPolicy) trimGhosts() {
+	for p.b1.Len() > 0 && p.t1.Len()+p.b1.Len() > p.capacity {
+		p.b1.PopBack()
+	}
+	for p.b2.Len() > 0 && p.t1.Len()+p.t2.Len()+p.b1.Len()+p.b2.Len() > 2*p.capacity {
+		p.b2.PopBack()
+	}
+}
+
+// Remove 实现EvictionPolicy接口。被删除的key不进入幽灵链表
+func (p *arcPolicy) Remove(key interface{}) {
+	if !p.t1.Remove(key) {
+		p.t2.Remove(key)
+	}
+}
+
+// Clear 实现EvictionPolicy接口
+func (p *arcPolicy) Clear() {
+	p.p = 0
+	p.t1.Clear()
+	p.t2.Clear()
+	p.b1.Clear()
+	p.b2.Clear()
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
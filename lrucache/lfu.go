@@ -0,0 +1,136 @@
+/*
+ * LFU淘汰策略
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package lrucache
+
+import (
+	"container/list"
+)
+
+// lfuEntry LFU中的key和访问次数
+type lfuEntry struct {
+	key  interface{}
+	freq int
+}
+
+// lfuPolicy LFU淘汰策略。key按访问次数分桶，淘汰访问次数最少的桶中最久未使用的key
+type lfuPolicy struct {
+	// buckets 访问次数到key链表的映射，只保存非空的桶
+	buckets map[int]*list.List
+	// minFreq 最少的访问次数，可能已过时，淘汰时修正
+	minFreq int
+
+	elements map[interface{}]*list.Element
+}
+
+// NewLFUPolicy 新建LFU淘汰策略，淘汰访问次数最少的key，次数相同时淘汰最久未使用的。
+// 访问次数不衰减，适用于热点稳定的负载
+func NewLFUPolicy(capacity int) EvictionPolicy {
+	return &lfuPolicy{
+		buckets:  make(map[int]*list.List),
+		elements: make(map[interface{}]*list.Element),
+	}
+}
+
+// push 将entry放入访问次数对应的桶
+func (p *lfuPolicy) push(entry *lfuEntry) {
+	bucket, ok := p.buckets[entry.freq]
+	if !ok {
+		bucket = list.New()
+		p.buckets[entry.freq] = bucket
+	}
+	p.elements[entry.key] = bucket.PushFront(entry)
+	if entry.freq < p.minFreq {
+		p.minFreq = entry.freq
+	}
+}
+
+// unlink 将元素从所在的桶中移除，删除空桶
+func (p *lfuPolicy) unlink(elem *list.Element) *lfuEntry {
+	entry := elem.Value.(*lfuEntry)
+	bucket := p.buckets[entry.freq]
+	bucket.Remove(elem)
+	if bucket.Len() == 0 {
+		delete(p.buckets, entry.freq)
+	}
+	return entry
+}
+
+// Add 实现EvictionPolicy接口
+func (p *lfuPolicy) Add(key interface{}) {
+	if _, ok := p.elements[key]; ok {
+		p.Access(key)
+		return
+	}
+	if len(p.elements) == 0 {
+		p.minFreq = 1
+	}
+	p.push(&lfuEntry{key: key, freq: 1})
+}
+
+// Access 实现EvictionPolicy接口
+func (p *lfuPolicy) Access(key interface{}) {
+	elem, ok := p.elements[key]
+	if !ok {
+		return
+	}
+	entry := p.unlink(elem)
+	entry.freq++
+	p.push(entry)
+}
+
+// Demote 实现EvictionPolicy接口，访问次数清零，最先淘汰
+func (p *lfuPolicy) Demote(key interface{}) {
+	elem, ok := p.elements[key]
+	if !ok {
+		return
+	}
+	entry := p.unlink(elem)
+	entry.freq = 0
+	p.push(entry)
+}
+
+// Evict 实现EvictionPolicy接口
+func (p *lfuPolicy) Evict() (interface{}, bool) {
+	if len(p.elements) == 0 {
+		return nil, false
+	}
+
+	bucket, ok := p.buckets[p.minFreq]
+	if !ok {
+		// 修正过时的最少访问次数
+		first := true
+		for freq := range p.buckets {
+			if first || freq < p.minFreq {
+				p.minFreq = freq
+				first = false
+			}
+		}
+		bucket = p.buckets[p.minFreq]
+	}
+
+	entry := p.unlink(bucket.Back())
+	delete(p.elements, entry.key)
+	return entry.key, true
+}
+
+// Remove 实现EvictionPolicy接口
+func (p *lfuPolicy) Remove(key interface{}) {
+	elem, ok := p.elements[key]
+	if !ok {
+		return
+	}
+	p.unlink(elem)
+	delete(p.elements, key)
+}
+
+// Clear 实现EvictionPolicy接口
+func (p *lfuPolicy) Clear() {
+	p.buckets = make(map[int]*list.List)
+	p.elements = make(map[interface{}]*list.Element)
+	p.minFreq = 0
+}
@@ -67,6 +67,15 @@ func (m *ListMap) Back() (value interface{}, ok bool) {
 	return nil, false
 }
 
+func (m *ListMap) BackKey() (key interface{}, ok bool) {
+	elem := m.sequence.Back()
+	if elem != nil {
+		entry := elem.Value.(*listEntry)
+		return entry.key, true
+	}
+	return nil, false
+}
+
 func (m *ListMap) PopFront() (value interface{}, ok bool) {
 	elem := m.sequence.Front()
 	if elem != nil {
@@ -131,3 +140,11 @@ func (m *ListMap) Clear() {
 	m.mapping = make(map[interface{}]*list.Element)
 	m.sequence = list.New()
 }
+
+func (m *ListMap) Keys() []interface{} {
+	keys := make([]interface{}, 0, m.sequence.Len())
+	for elem := m.sequence.Front(); elem != nil; elem = elem.Next() {
+		keys = append(keys, elem.Value.(*listEntry).key)
+	}
+	return keys
+}
@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/jinzhu/copier"
+	"github.com/vmihailenco/msgpack"
+	"github.com/wencan/cachex"
 )
 
 // NotFound 没找到错误
@@ -35,18 +37,70 @@ var expired = Expired{}
 
 type cacheEntry struct {
 	value      interface{}
+	setTime    time.Time
 	expireTime time.Time
+	version    uint64
+	// cost 数据的字节数，未配置字节预算时为0
+	cost int64
 }
 
+// Sizer 返回自身的字节数的值接口。缓存的值实现该接口时，用于字节预算
+type Sizer interface {
+	Size() int64
+}
+
+// CostFunc 返回缓存数据字节数的函数签名
+type CostFunc func(key, value interface{}) int64
+
+// lease 租约
+type lease struct {
+	token      uint64
+	expireTime time.Time
+}
+
+// minLeasePurge 清理过期租约的最小租约数
+const minLeasePurge = 1024
+
+// minTombstonePurge 清理墓碑的最小墓碑数
+const minTombstonePurge = 1024
+
 // LRUCache 本地LRU缓存类，实现了cachex.DeletableStorage接口
 type LRUCache struct {
 	MaxEntries int
 	defaultTTL time.Duration
 
+	// jitter 写入时的TTL抖动策略
+	jitter cachex.Jitter
+
+	// keyFunc key规范化函数，为nil时使用cachex.CanonicalKey
+	keyFunc cachex.KeyFunc
+
 	Mapping *ListMap
 
 	lock sync.Mutex
 
+	// version 版本计数，每次写入、删除递增
+	version uint64
+	// tombstones 被删除的key的墓碑，值为删除时的版本
+	tombstones map[interface{}]uint64
+	// removedVersion 最近一次批量删除、清理墓碑时的版本，不存在的key的版本不低于它
+	removedVersion uint64
+
+	// leaseTTL 租约有效期，为0时不发放租约
+	leaseTTL time.Duration
+	// leases 未完成的租约
+	leases map[interface{}]*lease
+
+	// policy 淘汰策略，为nil时按Mapping的顺序淘汰最久未使用的数据
+	policy EvictionPolicy
+
+	// maxBytes 全部数据的字节预算，为0时不限制
+	maxBytes int64
+	// bytes 当前全部数据的字节数
+	bytes int64
+	// costFunc 数据字节数函数
+	costFunc CostFunc
+
 	entryPool sync.Pool
 }
 
@@ -64,114 +118,490 @@ func NewLRUCache(maxEntries int, defaultTTL time.Duration) *LRUCache {
 	}
 }
 
+// NewLRUCacheWithPolicy 新建使用指定淘汰策略的本地缓存类，如NewLFUPolicy、NewARCPolicy、NewTwoQueuePolicy、NewFIFOPolicy。
+// 淘汰策略只决定容量或字节预算超出时淘汰哪个数据，过期、删除、清空的行为与LRU相同
+func NewLRUCacheWithPolicy(maxEntries int, defaultTTL time.Duration, newPolicy PolicyFunc) *LRUCache {
+	c := NewLRUCache(maxEntries, defaultTTL)
+	c.policy = newPolicy(maxEntries)
+	return c
+}
+
+// UseTTLJitter 设置写入时的TTL抖动策略，作用于每一次写入（Cachex已抖动过的TTL除外，见cachex.JitterTTL）。默认不抖动。
+func (c *LRUCache) UseTTLJitter(jitter cachex.Jitter) {
+	c.jitter = jitter
+}
+
+// UseKeyFunc 设置key规范化函数。默认使用cachex.CanonicalKey
+func (c *LRUCache) UseKeyFunc(keyFunc cachex.KeyFunc) {
+	c.keyFunc = keyFunc
+}
+
+// UseLease 启用租约，设置租约有效期。默认不启用。
+// 启用后，没找到、数据已过期时为key发放租约，同一个key未完成的租约共享。
+// 写入、删除key使该key的租约失效，查询耗时超过租约有效期的结果也会被放弃
+func (c *LRUCache) UseLease(leaseTTL time.Duration) {
+	c.leaseTTL = leaseTTL
+}
+
+// UseTinyLFU 使用W-TinyLFU淘汰策略代替LRU，需在使用前设置。默认为LRU。
+// 新数据先进入小的准入窗口，离开窗口后与最久未使用的数据比较（定期衰减的）访问频率，频率低的被淘汰，
+// 避免一次性的扫描（如遍历全部用户的批处理）挤出热点数据。容量取自MaxEntries
+func (c *LRUCache) UseTinyLFU() {
+	c.policy = NewTinyLFUPolicy(c.MaxEntries)
+}
+
+// UseMaxBytes 设置全部数据的字节预算，超出时淘汰最久未使用的数据，与MaxEntries同时生效。默认不限制。
+// 数据的字节数依次取自UseCostFunc设置的函数、实现了Sizer接口的值、值的msgpack编码长度（估算）。
+// 字节数超过预算的单个数据不会被缓存
+func (c *LRUCache) UseMaxBytes(maxBytes int64) {
+	c.maxBytes = maxBytes
+}
+
+// UseCostFunc 设置数据字节数函数，用于字节预算，优先于Sizer接口和编码估算
+func (c *LRUCache) UseCostFunc(costFunc CostFunc) {
+	c.costFunc = costFunc
+}
+
+// Bytes 当前全部数据的字节数。未设置字节预算时为0
+func (c *LRUCache) Bytes() int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.bytes
+}
+
+// costOf 数据的字节数。未设置字节预算时为0
+func (c *LRUCache) costOf(key, value interface{}) int64 {
+	if c.maxBytes <= 0 {
+		return 0
+	}
+	if c.costFunc != nil {
+		return c.costFunc(key, value)
+	}
+	if sizer, ok := value.(Sizer); ok {
+		return sizer.Size()
+	}
+	data, err := msgpack.Marshal(value)
+	if err != nil {
+		// 无法编码的数据，至少按1字节计算
+		return 1
+	}
+	return int64(len(data))
+}
+
+// cacheKey 返回规范化的key，支持切片、map等不可比较的key
+func (c *LRUCache) cacheKey(key interface{}) (interface{}, error) {
+	if c.keyFunc != nil {
+		if keyable, ok := key.(cachex.Keyable); ok {
+			key = keyable.CacheKey()
+		}
+		return c.keyFunc(key)
+	}
+	return cachex.CanonicalKey(key)
+}
+
 // Set 设置缓存数据
 func (c *LRUCache) Set(ctx context.Context, key, value interface{}) error {
 	return c.SetWithTTL(ctx, key, value, c.defaultTTL)
 }
 
-// SetWithTTL 设置缓存数据，并定制TTL
+// SetWithTTL 设置缓存数据，并定制TTL。覆盖已存在的数据
 func (c *LRUCache) SetWithTTL(ctx context.Context, key, value interface{}, TTL time.Duration) error {
+	_, err := c.SetWithTTLAndMode(ctx, key, value, TTL, cachex.WriteOverwrite)
+	return err
+}
+
+// SetWithMode 按写入模式设置缓存数据，实现cachex.ModeWritableStorage接口。返回是否写入
+func (c *LRUCache) SetWithMode(ctx context.Context, key, value interface{}, mode cachex.WriteMode) (bool, error) {
+	return c.SetWithTTLAndMode(ctx, key, value, c.defaultTTL, mode)
+}
+
+// Add 仅数据不存在时设置缓存数据，返回是否写入
+func (c *LRUCache) Add(ctx context.Context, key, value interface{}) (bool, error) {
+	return c.SetWithMode(ctx, key, value, cachex.WriteAdd)
+}
+
+// SetWithTTLAndMode 按写入模式设置缓存数据，并定制TTL。返回是否写入。
+// 已过期的数据视为不存在
+func (c *LRUCache) SetWithTTLAndMode(ctx context.Context, key, value interface{}, TTL time.Duration, mode cachex.WriteMode) (bool, error) {
+	key, saved, TTL, cost, err := c.prepare(ctx, key, value, TTL)
+	if err != nil {
+		return false, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	item, ok := c.Mapping.Get(key)
+	exists := ok && !c.expired(item.(*cacheEntry), time.Now())
+	if (mode == cachex.WriteAdd && exists) || (mode == cachex.WriteReplace && !exists) {
+		return false, nil
+	}
+
+	c.store(key, saved, TTL, cost)
+	return true, nil
+}
+
+// CompareAndSet 仅当key的版本仍为version时设置缓存数据，实现cachex.CASStorage接口。TTL为0时使用默认TTL。返回是否写入
+func (c *LRUCache) CompareAndSet(ctx context.Context, key, value interface{}, version uint64, TTL time.Duration) (bool, error) {
+	if TTL == 0 {
+		TTL = c.defaultTTL
+	}
+	key, saved, TTL, cost, err := c.prepare(ctx, key, value, TTL)
+	if err != nil {
+		return false, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.versionOf(key) != version {
+		return false, nil
+	}
+
+	c.store(key, saved, TTL, cost)
+	return true, nil
+}
+
+// SetWithLease 凭租约设置缓存数据，实现cachex.LeasableStorage接口。TTL为0时使用默认TTL。
+// 租约已失效时放弃写入，返回false。未启用租约时，租约即版本令牌，同CompareAndSet
+func (c *LRUCache) SetWithLease(ctx context.Context, key, value interface{}, lease uint64, TTL time.Duration) (bool, error) {
+	if c.leaseTTL == 0 {
+		return c.CompareAndSet(ctx, key, value, lease, TTL)
+	}
+
+	if TTL == 0 {
+		TTL = c.defaultTTL
+	}
+	key, saved, TTL, cost, err := c.prepare(ctx, key, value, TTL)
+	if err != nil {
+		return false, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	l, ok := c.leases[key]
+	if !ok || l.token != lease || time.Now().After(l.expireTime) {
+		return false, nil
+	}
+
+	c.store(key, saved, TTL, cost)
+	return true, nil
+}
+
+// prepare 规范化key，深拷贝value，抖动TTL，计算字节数
+func (c *LRUCache) prepare(ctx context.Context, key, value interface{}, TTL time.Duration) (interface{}, interface{}, time.Duration, int64, error) {
+	key, err := c.cacheKey(key)
+	if err != nil {
+		return nil, nil, 0, 0, err
+	}
+
 	// 深拷贝
 	t := reflect.ValueOf(value)
 	if t.Kind() == reflect.Ptr {
 		t = t.Elem()
 	}
 	saved := reflect.New(t.Type()).Interface()
-	err := copier.Copy(saved, t.Interface())
+	err = copier.Copy(saved, t.Interface())
 	if err != nil {
-		return err
+		return nil, nil, 0, 0, err
 	}
 
-	c.lock.Lock()
-	defer c.lock.Unlock()
+	TTL = cachex.JitterTTL(ctx, c.jitter, TTL)
+	return key, saved, TTL, c.costOf(key, value), nil
+}
+
+// store 写入缓存数据，超出容量或字节预算时淘汰最久未使用的数据。调用者需持有锁
+func (c *LRUCache) store(key, saved interface{}, TTL time.Duration, cost int64) {
+	c.version++
+	delete(c.leases, key)
+
+	if c.maxBytes > 0 && cost > c.maxBytes {
+		// 超过预算的单个数据不缓存，移除旧数据
+		if c.pop(key) {
+			c.tombstone(key)
+		}
+		return
+	}
 
 	item, ok := c.Mapping.Get(key)
 	if ok {
 		entry := item.(*cacheEntry)
+		c.bytes += cost - entry.cost
 		entry.value = saved
-		entry.expireTime = time.Now().Add(TTL)
+		entry.setTime = time.Now()
+		entry.expireTime = entry.setTime.Add(TTL)
+		entry.version = c.version
+		entry.cost = cost
 
 		c.Mapping.MoveToFront(key)
+		if c.policy != nil {
+			c.policy.Access(key)
+		}
 	} else {
 		entry := c.entryPool.Get().(*cacheEntry)
 		entry.value = saved
-		entry.expireTime = time.Now().Add(TTL)
+		entry.setTime = time.Now()
+		entry.expireTime = entry.setTime.Add(TTL)
+		entry.version = c.version
+		entry.cost = cost
+		c.bytes += cost
 
 		c.Mapping.PushFront(key, entry)
+		if c.policy != nil {
+			c.policy.Add(key)
+		}
+	}
 
-		if c.MaxEntries > 0 {
-			for c.Mapping.Len() > c.MaxEntries {
-				entry, _ := c.Mapping.PopBack()
-				if entry != nil {
-					c.entryPool.Put(entry)
-				}
+	// 淘汰不是失效，不改变版本：被淘汰的数据仍是有效的，回写不应被放弃
+	for (c.MaxEntries > 0 && c.Mapping.Len() > c.MaxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		if !c.evict() {
+			break
+		}
+	}
+}
+
+// evict 淘汰一个数据，没有数据返回false。调用者需持有锁
+func (c *LRUCache) evict() bool {
+	if c.policy != nil {
+		key, ok := c.Mapping.BackKey()
+		if !ok {
+			return false
+		}
+		entry, _ := c.Mapping.Get(key)
+		if c.expired(entry.(*cacheEntry), time.Now()) {
+			// 读到的过期数据已移到队尾，不论淘汰策略，优先淘汰
+			c.policy.Remove(key)
+		} else {
+			// 淘汰策略已移除key
+			key, ok = c.policy.Evict()
+			if !ok {
+				return false
 			}
 		}
+		entry, _ = c.Mapping.Pop(key)
+		c.release(entry)
+		return true
 	}
 
-	return nil
+	entry, _ := c.Mapping.PopBack()
+	if entry == nil {
+		return false
+	}
+	c.release(entry)
+	return true
+}
+
+// pop 移除key的数据，不存在返回false。调用者需持有锁
+func (c *LRUCache) pop(key interface{}) bool {
+	entry, ok := c.Mapping.Pop(key)
+	if !ok {
+		return false
+	}
+	if c.policy != nil {
+		c.policy.Remove(key)
+	}
+	c.release(entry)
+	return true
+}
+
+// release 减去已移除数据的字节数，回收数据项。调用者需持有锁
+func (c *LRUCache) release(entry interface{}) {
+	if entry == nil {
+		return
+	}
+	c.bytes -= entry.(*cacheEntry).cost
+	c.entryPool.Put(entry)
+}
+
+// versionOf 返回key的版本。调用者需持有锁
+func (c *LRUCache) versionOf(key interface{}) uint64 {
+	item, ok := c.Mapping.Get(key)
+	if ok {
+		return item.(*cacheEntry).version
+	}
+	return c.missingVersion(key)
+}
+
+// missingVersion 返回不存在的key的版本：key的墓碑版本和最近一次批量删除时的版本中较大的。调用者需持有锁
+func (c *LRUCache) missingVersion(key interface{}) uint64 {
+	if version, ok := c.tombstones[key]; ok && version > c.removedVersion {
+		return version
+	}
+	return c.removedVersion
+}
+
+// leaseOf 返回key未完成的租约，没有则发放新租约。调用者需持有锁
+func (c *LRUCache) leaseOf(key interface{}, now time.Time) uint64 {
+	if l, ok := c.leases[key]; ok && !now.After(l.expireTime) {
+		return l.token
+	}
+
+	if c.leases == nil {
+		c.leases = make(map[interface{}]*lease)
+	}
+	if len(c.leases) >= minLeasePurge && len(c.leases) >= c.MaxEntries {
+		for k, l := range c.leases {
+			if now.After(l.expireTime) {
+				delete(c.leases, k)
+			}
+		}
+	}
+
+	c.version++
+	c.leases[key] = &lease{token: c.version, expireTime: now.Add(c.leaseTTL)}
+	return c.version
+}
+
+// tombstone 记录key被删除，使此前得到的该key的版本失效。调用者需持有锁
+func (c *LRUCache) tombstone(key interface{}) {
+	c.version++
+
+	if len(c.tombstones) >= minTombstonePurge && len(c.tombstones) >= c.MaxEntries {
+		// 墓碑过多时清理全部墓碑，改为批量删除，只影响清理时未完成的回写
+		c.removed()
+		return
+	}
+	if c.tombstones == nil {
+		c.tombstones = make(map[interface{}]uint64)
+	}
+	c.tombstones[key] = c.version
+}
+
+// removed 记录批量删除，使此前全部不存在的key的版本失效，并清理墓碑。调用者需持有锁
+func (c *LRUCache) removed() {
+	c.version++
+	c.removedVersion = c.version
+	c.tombstones = nil
+}
+
+// expired 数据是否已过期。未配置默认TTL时不过期
+func (c *LRUCache) expired(entry *cacheEntry, now time.Time) bool {
+	return c.defaultTTL != 0 && now.After(entry.expireTime)
 }
 
 // Get 获取缓存数据
 func (c *LRUCache) Get(ctx context.Context, key, value interface{}) error {
+	_, err := c.GetWithAge(ctx, key, value)
+	return err
+}
+
+// GetWithAge 获取缓存数据和数据的年龄，实现cachex.AgeableStorage接口
+func (c *LRUCache) GetWithAge(ctx context.Context, key, value interface{}) (time.Duration, error) {
+	age, _, err := c.get(key, value, false)
+	return age, err
+}
+
+// GetWithVersion 获取缓存数据和版本令牌，实现cachex.CASStorage接口。
+// 版本按key记录：写入、删除key使该key此前的版本失效，淘汰其它key不影响。
+// 被删除的key保留墓碑记录删除时的版本；墓碑过多时被清理，同批量删除（DelPattern、Clear），使此前全部没找到得到的版本失效
+func (c *LRUCache) GetWithVersion(ctx context.Context, key, value interface{}) (uint64, error) {
+	_, version, err := c.get(key, value, false)
+	return version, err
+}
+
+// GetWithLease 获取缓存数据和租约，实现cachex.LeasableStorage接口。
+// 没找到、数据已过期时发放租约。未启用租约时，租约即版本令牌，同GetWithVersion
+func (c *LRUCache) GetWithLease(ctx context.Context, key, value interface{}) (uint64, error) {
+	_, token, err := c.get(key, value, c.leaseTTL != 0)
+	return token, err
+}
+
+// get 获取缓存数据、数据的年龄和版本。withLease为true时，没找到、数据已过期返回租约代替版本
+func (c *LRUCache) get(key, value interface{}, withLease bool) (time.Duration, uint64, error) {
 	if v := reflect.ValueOf(value); v.Kind() != reflect.Ptr || v.IsNil() {
 		panic("value not is non-nil pointer")
 	}
 
+	key, err := c.cacheKey(key)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	saved, age, token, err := c.lookup(key, withLease)
+	if saved == nil {
+		return age, token, err
+	}
+
+	// 缓存的数据写入后不再修改，在锁外复制
+	copyErr := copier.Copy(value, saved)
+	if copyErr != nil {
+		return 0, 0, copyErr
+	}
+	return age, token, err
+}
+
+// lookup 查找缓存的数据，返回数据、数据的年龄和版本（或租约）。没找到时数据为nil
+func (c *LRUCache) lookup(key interface{}, withLease bool) (interface{}, time.Duration, uint64, error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
+	now := time.Now()
 	item, ok := c.Mapping.Get(key)
 	if ok {
 		entry := item.(*cacheEntry)
-		if c.defaultTTL != 0 {
-			if time.Now().After(entry.expireTime) {
-				// 将过期数据移到队列后方，而不是删除
-				// 如果查询出错，还可能使用保留的过期数据
-				c.Mapping.MoveToBack(key)
-				// c.Mapping.Pop(key)
-				// c.entryPool.Put(entry)
-				err := copier.Copy(value, entry.value)
-				if err != nil {
-					return err
-				}
-				// 返回过期数据同时，返回expired错误
-				return expired
+		age := now.Sub(entry.setTime)
+		if c.expired(entry, now) {
+			// 将过期数据移到队列后方，而不是删除
+			// 如果查询出错，还可能使用保留的过期数据
+			c.Mapping.MoveToBack(key)
+			if c.policy != nil {
+				c.policy.Demote(key)
+			}
+			// 返回过期数据同时，返回expired错误
+			if withLease {
+				return entry.value, age, c.leaseOf(key, now), expired
 			}
+			return entry.value, age, entry.version, expired
 		}
 
 		c.Mapping.MoveToFront(key)
-		err := copier.Copy(value, entry.value)
-		if err != nil {
-			return err
+		if c.policy != nil {
+			c.policy.Access(key)
 		}
-		return err
+		return entry.value, age, entry.version, nil
 	}
 
-	return notFound
+	if withLease {
+		return nil, 0, c.leaseOf(key, now), notFound
+	}
+	return nil, 0, c.missingVersion(key), notFound
 }
 
 // Remove 删除缓存数据
 func (c *LRUCache) Remove(key interface{}) {
+	key, err := c.cacheKey(key)
+	if err != nil {
+		return
+	}
+
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	entry, _ := c.Mapping.Pop(key)
-	if entry != nil {
-		c.entryPool.Put(entry)
-	}
+	c.pop(key)
+	delete(c.leases, key)
+	c.tombstone(key)
 }
 
 // Del 删除缓存数据
 func (c *LRUCache) Del(ctx context.Context, keys ...interface{}) error {
+	cacheKeys := make([]interface{}, len(keys))
+	for idx, key := range keys {
+		cacheKey, err := c.cacheKey(key)
+		if err != nil {
+			return err
+		}
+		cacheKeys[idx] = cacheKey
+	}
+
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
-	for _, key := range keys {
-		entry, _ := c.Mapping.Pop(key)
-		if entry != nil {
-			c.entryPool.Put(entry)
-		}
+	for _, key := range cacheKeys {
+		c.pop(key)
+		delete(c.leases, key)
+		c.tombstone(key)
 	}
 	return nil
 }
@@ -184,6 +614,33 @@ func (c *LRUCache) Len() int {
 	return c.Mapping.Len()
 }
 
+// DelPattern 删除key匹配glob模式的缓存数据，实现cachex.PatternDeletableStorage接口。
+// 规范化后的key转为字符串（见cachex.KeyString）再匹配，无法转为字符串的key不匹配
+func (c *LRUCache) DelPattern(ctx context.Context, pattern string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, key := range c.Mapping.Keys() {
+		skey, err := cachex.KeyString(key)
+		if err != nil {
+			continue
+		}
+		if !cachex.MatchPattern(pattern, skey) {
+			continue
+		}
+
+		c.pop(key)
+	}
+	for key := range c.leases {
+		skey, err := cachex.KeyString(key)
+		if err == nil && cachex.MatchPattern(pattern, skey) {
+			delete(c.leases, key)
+		}
+	}
+	c.removed()
+	return nil
+}
+
 // Clear 清空缓存的数据
 func (c *LRUCache) Clear(ctx context.Context) error {
 	c.lock.Lock()
@@ -191,9 +648,12 @@ func (c *LRUCache) Clear(ctx context.Context) error {
 
 	for c.Mapping.Len() != 0 {
 		entry, _ := c.Mapping.PopBack()
-		if entry != nil {
-			c.entryPool.Put(entry)
-		}
+		c.release(entry)
+	}
+	if c.policy != nil {
+		c.policy.Clear()
 	}
+	c.leases = nil
+	c.removed()
 	return nil
 }
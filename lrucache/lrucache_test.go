@@ -5,6 +5,9 @@ package lrucache
 
 import (
 	"context"
+	"math/rand"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -137,3 +140,407 @@ func TestLRUCacheDel(t *testing.T) {
 	err = cache.Get(ctx, value, &cached)
 	assert.Equal(t, NotFound{}, err)
 }
+
+func TestLRUCacheTTLJitter(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRUCache(0, time.Millisecond*10)
+	cache.UseTTLJitter(cachex.NewPercentJitter(0.5, rand.NewSource(1)))
+
+	for i := 0; i < 10; i++ {
+		err := cache.Set(ctx, i, i)
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+
+	// 过期时间被打散
+	expireTimes := make(map[time.Time]bool)
+	for i := 0; i < 10; i++ {
+		item, _ := cache.Mapping.Get(i)
+		expireTimes[item.(*cacheEntry).expireTime] = true
+	}
+	assert.True(t, len(expireTimes) > 1)
+}
+
+func TestLRUCacheGetWithAge(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRUCache(0, 0)
+	assert.Implements(t, (*cachex.AgeableStorage)(nil), cache)
+
+	err := cache.Set(ctx, "test", "test")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	time.Sleep(time.Millisecond * 10)
+
+	var cached string
+	age, err := cache.GetWithAge(ctx, "test", &cached)
+	assert.NoError(t, err)
+	assert.Equal(t, "test", cached)
+	assert.True(t, age >= time.Millisecond*10)
+}
+
+func TestLRUCacheCompositeKey(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRUCache(0, 0)
+
+	err := cache.Set(ctx, []string{"a", "b"}, "ab")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var cached string
+	err = cache.Get(ctx, []string{"a", "b"}, &cached)
+	assert.NoError(t, err)
+	assert.Equal(t, "ab", cached)
+
+	err = cache.Get(ctx, map[string]int{"a": 1}, &cached)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+
+	err = cache.Del(ctx, []string{"a", "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestLRUCacheDelPattern(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRUCache(10, 0)
+
+	for _, key := range []interface{}{"user:1", "user:2", "order:1", 3, []int{1, 2}} {
+		err := cache.Set(ctx, key, key)
+		assert.NoError(t, err)
+	}
+
+	err := cache.DelPattern(ctx, "user:*")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, cache.Len())
+
+	err = cache.DelPattern(ctx, "[1-3]")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, cache.Len())
+
+	err = cache.DelPattern(ctx, "[[]*")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cache.Len())
+
+	var value string
+	err = cache.Get(ctx, "order:1", &value)
+	assert.NoError(t, err)
+}
+
+func TestLRUCacheWriteMode(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRUCache(10, time.Millisecond*10)
+
+	written, err := cache.SetWithMode(ctx, "key", "replace", cachex.WriteReplace)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	written, err = cache.Add(ctx, "key", "add")
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	written, err = cache.Add(ctx, "key", "add-again")
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	written, err = cache.SetWithMode(ctx, "key", "replace", cachex.WriteReplace)
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	var value string
+	err = cache.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "replace", value)
+
+	// 过期数据视为不存在
+	time.Sleep(time.Millisecond * 20)
+	written, err = cache.SetWithMode(ctx, "key", "replace-expired", cachex.WriteReplace)
+	assert.NoError(t, err)
+	assert.False(t, written)
+	written, err = cache.Add(ctx, "key", "add-expired")
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	// Set总是覆盖
+	err = cache.Set(ctx, "key", "set")
+	assert.NoError(t, err)
+	err = cache.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "set", value)
+}
+
+func TestLRUCacheCompareAndSet(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRUCache(10, time.Minute)
+	assert.Implements(t, (*cachex.CASStorage)(nil), cache)
+
+	var value string
+	version, err := cache.GetWithVersion(ctx, "key", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+
+	written, err := cache.CompareAndSet(ctx, "key", "first", version, 0)
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	// 版本已变化
+	written, err = cache.CompareAndSet(ctx, "key", "second", version, 0)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	version, err = cache.GetWithVersion(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", value)
+	written, err = cache.CompareAndSet(ctx, "key", "second", version, 0)
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	// 写入后版本变化
+	err = cache.Set(ctx, "key", "third")
+	assert.NoError(t, err)
+	written, err = cache.CompareAndSet(ctx, "key", "fourth", version, 0)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	// 没找到后，查询期间被删除
+	version, err = cache.GetWithVersion(ctx, "other", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	err = cache.Del(ctx, "other")
+	assert.NoError(t, err)
+	written, err = cache.CompareAndSet(ctx, "other", "stale", version, 0)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	// 删除、淘汰其它key不影响版本
+	version, err = cache.GetWithVersion(ctx, "other", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	err = cache.Del(ctx, "key")
+	assert.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		err = cache.Set(ctx, i, "filler")
+		assert.NoError(t, err)
+	}
+	written, err = cache.CompareAndSet(ctx, "other", "fresh", version, 0)
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	// 批量删除使全部不存在的key的版本失效
+	version, err = cache.GetWithVersion(ctx, "missing", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	err = cache.DelPattern(ctx, "miss*")
+	assert.NoError(t, err)
+	written, err = cache.CompareAndSet(ctx, "missing", "stale", version, 0)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	err = cache.Get(ctx, "other", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "fresh", value)
+}
+
+func TestLRUCacheCachexConcurrentMisses(t *testing.T) {
+	ctx := context.Background()
+
+	storages := map[string]cachex.Storage{
+		"lru":     NewLRUCache(10, time.Minute),
+		"sharded": NewShardedLRUCache(1, 10, time.Minute),
+	}
+	for name, cache := range storages {
+		// 缓存已满
+		for i := 0; i < 10; i++ {
+			err := cache.Set(ctx, i, i)
+			assert.NoError(t, err, name)
+		}
+
+		query := func(ctx context.Context, key, value interface{}) error {
+			time.Sleep(time.Millisecond * 10)
+			*value.(*int) = key.(int)
+			return nil
+		}
+		c := cachex.NewCachex(cache, cachex.QueryFunc(query))
+
+		// 并发没找到，回写互相淘汰对方的数据，但都应写入
+		var wg sync.WaitGroup
+		for i := 100; i < 110; i++ {
+			wg.Add(1)
+			go func(key int) {
+				defer wg.Done()
+				var value int
+				err := c.Get(ctx, key, &value)
+				assert.NoError(t, err, name)
+			}(i)
+		}
+		wg.Wait()
+
+		for i := 100; i < 110; i++ {
+			var value int
+			err := cache.Get(ctx, i, &value)
+			assert.NoError(t, err, name)
+			assert.Equal(t, i, value, name)
+		}
+	}
+}
+
+func TestLRUCacheCachexCompareAndSet(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRUCache(10, time.Minute)
+
+	var c *cachex.Cachex
+	query := func(ctx context.Context, key, value interface{}) error {
+		// 查询期间数据被更新并失效
+		err := c.Del(ctx, key)
+		assert.NoError(t, err)
+
+		*value.(*string) = "stale"
+		return nil
+	}
+	c = cachex.NewCachex(cache, cachex.QueryFunc(query))
+
+	var value string
+	err := c.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "stale", value)
+
+	// 过时的查询结果没有写入
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestLRUCacheLease(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRUCache(10, time.Minute)
+	cache.UseLease(time.Minute)
+	assert.Implements(t, (*cachex.LeasableStorage)(nil), cache)
+
+	var value string
+	lease, err := cache.GetWithLease(ctx, "key", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	assert.NotEqual(t, uint64(0), lease)
+
+	// 未完成的租约共享
+	shared, err := cache.GetWithLease(ctx, "key", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	assert.Equal(t, lease, shared)
+
+	// 删除其它key不影响租约
+	err = cache.Del(ctx, "other")
+	assert.NoError(t, err)
+	written, err := cache.SetWithLease(ctx, "key", "first", lease, 0)
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	// 租约已使用
+	written, err = cache.SetWithLease(ctx, "key", "second", lease, 0)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	// 没找到后，查询期间被删除
+	lease, err = cache.GetWithLease(ctx, "deleted", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	err = cache.Del(ctx, "deleted")
+	assert.NoError(t, err)
+	written, err = cache.SetWithLease(ctx, "deleted", "stale", lease, 0)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	// 租约过期
+	cache.UseLease(time.Millisecond)
+	lease, err = cache.GetWithLease(ctx, "slow", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	time.Sleep(time.Millisecond * 5)
+	written, err = cache.SetWithLease(ctx, "slow", "stale", lease, 0)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	err = cache.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", value)
+	assert.Equal(t, 1, cache.Len())
+}
+
+// sizedValue 实现Sizer接口的值
+type sizedValue struct {
+	Data []byte
+}
+
+func (v sizedValue) Size() int64 {
+	return int64(len(v.Data))
+}
+
+func TestLRUCacheMaxBytes(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRUCache(0, time.Minute)
+	cache.UseMaxBytes(1000)
+
+	for i := 0; i < 10; i++ {
+		err := cache.Set(ctx, i, sizedValue{Data: make([]byte, 100)})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 10, cache.Len())
+	assert.Equal(t, int64(1000), cache.Bytes())
+
+	// 淘汰最久未使用的数据
+	var value sizedValue
+	err := cache.Get(ctx, 0, &value)
+	assert.NoError(t, err)
+	err = cache.Set(ctx, 10, sizedValue{Data: make([]byte, 250)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(950), cache.Bytes())
+	for _, key := range []int{1, 2, 3} {
+		err = cache.Get(ctx, key, &value)
+		assert.Implements(t, (*cachex.NotFound)(nil), err)
+	}
+	err = cache.Get(ctx, 0, &value)
+	assert.NoError(t, err)
+
+	// 覆盖更新字节数
+	err = cache.Set(ctx, 10, sizedValue{Data: make([]byte, 50)})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(750), cache.Bytes())
+
+	// 超过预算的单个数据不缓存
+	err = cache.Set(ctx, 0, sizedValue{Data: make([]byte, 2000)})
+	assert.NoError(t, err)
+	err = cache.Get(ctx, 0, &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	assert.Equal(t, int64(650), cache.Bytes())
+
+	err = cache.Del(ctx, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(600), cache.Bytes())
+	err = cache.Clear(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), cache.Bytes())
+}
+
+func TestLRUCacheCostFunc(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRUCache(0, time.Minute)
+	cache.UseMaxBytes(10)
+	cache.UseCostFunc(func(key, value interface{}) int64 {
+		return int64(len(value.(string)))
+	})
+
+	err := cache.Set(ctx, 1, "12345")
+	assert.NoError(t, err)
+	err = cache.Set(ctx, 2, "123")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8), cache.Bytes())
+	err = cache.Set(ctx, 3, "123")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), cache.Bytes())
+	assert.Equal(t, 2, cache.Len())
+
+	// 编码估算
+	cache = NewLRUCache(0, time.Minute)
+	cache.UseMaxBytes(1 << 20)
+	err = cache.Set(ctx, 1, strings.Repeat("x", 1000))
+	assert.NoError(t, err)
+	assert.True(t, cache.Bytes() >= 1000 && cache.Bytes() < 1100)
+
+	// 未设置字节预算
+	cache = NewLRUCache(0, time.Minute)
+	err = cache.Set(ctx, 1, "value")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), cache.Bytes())
+}
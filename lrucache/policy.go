@@ -0,0 +1,167 @@
+/*
+ * 可插拔的淘汰策略
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package lrucache
+
+import (
+	"container/list"
+)
+
+// EvictionPolicy 淘汰策略接口。只维护key的淘汰顺序，数据由LRUCache保存。
+// 方法在LRUCache的锁内调用，实现无需并发安全
+type EvictionPolicy interface {
+	// Add 加入新的key
+	Add(key interface{})
+
+	// Access 记录已有key的访问（命中、覆盖写入）
+	Access(key interface{})
+
+	// Demote 数据已过期，降低key的保留优先级，应尽量先淘汰
+	Demote(key interface{})
+
+	// Evict 选出并移除下一个淘汰的key。没有key时ok返回false
+	Evict() (key interface{}, ok bool)
+
+	// Remove 移除被删除的key
+	Remove(key interface{})
+
+	// Clear 移除全部key
+	Clear()
+}
+
+// PolicyFunc 新建淘汰策略的函数签名。capacity为缓存容量（MaxEntries），为0时不限制
+type PolicyFunc func(capacity int) EvictionPolicy
+
+// keyList 可按key查找的key链表，队首为最近加入或访问的key
+type keyList struct {
+	order    *list.List
+	elements map[interface{}]*list.Element
+}
+
+func newKeyList() *keyList {
+	return &keyList{
+		order:    list.New(),
+		elements: make(map[interface{}]*list.Element),
+	}
+}
+
+// Contains key是否在链表中
+func (l *keyList) Contains(key interface{}) bool {
+	_, ok := l.elements[key]
+	return ok
+}
+
+// Len 链表长度
+func (l *keyList) Len() int {
+	return l.order.Len()
+}
+
+// PushFront 将key加入队首。调用者需保证key不在链表中
+func (l *keyList) PushFront(key interface{}) {
+	l.elements[key] = l.order.PushFront(key)
+}
+
+// MoveToFront 将key移到队首
+func (l *keyList) MoveToFront(key interface{}) {
+	if elem, ok := l.elements[key]; ok {
+		l.order.MoveToFront(elem)
+	}
+}
+
+// MoveToBack 将key移到队尾
+func (l *keyList) MoveToBack(key interface{}) {
+	if elem, ok := l.elements[key]; ok {
+		l.order.MoveToBack(elem)
+	}
+}
+
+// Remove 移除key，不存在返回false
+func (l *keyList) Remove(key interface{}) bool {
+	elem, ok := l.elements[key]
+	if !ok {
+		return false
+	}
+	l.order.Remove(elem)
+	delete(l.elements, key)
+	return true
+}
+
+// PopBack 移除并返回队尾的key
+func (l *keyList) PopBack() (interface{}, bool) {
+	elem := l.order.Back()
+	if elem == nil {
+		return nil, false
+	}
+	l.order.Remove(elem)
+	delete(l.elements, elem.Value)
+	return elem.Value, true
+}
+
+// Clear 清空
+func (l *keyList) Clear() {
+	l.order.Init()
+	l.elements = make(map[interface{}]*list.Element)
+}
+
+// listPolicy 基于单个key链表的淘汰策略，新加入的key在队首，从队尾淘汰
+type listPolicy struct {
+	// moveOnAccess 访问时是否移到队首
+	moveOnAccess bool
+
+	keys *keyList
+}
+
+// NewLRUPolicy 新建LRU淘汰策略，淘汰最久未使用的key。同LRUCache的默认行为
+func NewLRUPolicy(capacity int) EvictionPolicy {
+	return &listPolicy{
+		moveOnAccess: true,
+		keys:         newKeyList(),
+	}
+}
+
+// NewFIFOPolicy 新建FIFO淘汰策略，淘汰最早加入的key，访问不改变顺序
+func NewFIFOPolicy(capacity int) EvictionPolicy {
+	return &listPolicy{
+		keys: newKeyList(),
+	}
+}
+
+// Add 实现EvictionPolicy接口
+func (p *listPolicy) Add(key interface{}) {
+	if p.keys.Contains(key) {
+		p.Access(key)
+		return
+	}
+	p.keys.PushFront(key)
+}
+
+// Access 实现EvictionPolicy接口
+func (p *listPolicy) Access(key interface{}) {
+	if p.moveOnAccess {
+		p.keys.MoveToFront(key)
+	}
+}
+
+// Demote 实现EvictionPolicy接口，移到队尾最先淘汰
+func (p *listPolicy) Demote(key interface{}) {
+	p.keys.MoveToBack(key)
+}
+
+// Evict 实现EvictionPolicy接口
+func (p *listPolicy) Evict() (interface{}, bool) {
+	return p.keys.PopBack()
+}
+
+// Remove 实现EvictionPolicy接口
+func (p *listPolicy) Remove(key interface{}) {
+	p.keys.Remove(key)
+}
+
+// Clear 实现EvictionPolicy接口
+func (p *listPolicy) Clear() {
+	p.keys.Clear()
+}
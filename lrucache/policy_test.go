@@ -0,0 +1,215 @@
+package lrucache
+
+// wencan
+// 2026-10-19
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex"
+)
+
+var testPolicies = map[string]PolicyFunc{
+	"lru":     NewLRUPolicy,
+	"fifo":    NewFIFOPolicy,
+	"lfu":     NewLFUPolicy,
+	"arc":     NewARCPolicy,
+	"2q":      NewTwoQueuePolicy,
+	"tinylfu": NewTinyLFUPolicy,
+}
+
+// evictAll 依次淘汰全部key，返回淘汰顺序
+func evictAll(p EvictionPolicy) []interface{} {
+	var keys []interface{}
+	for {
+		key, ok := p.Evict()
+		if !ok {
+			return keys
+		}
+		keys = append(keys, key)
+	}
+}
+
+func TestEvictionPolicy(t *testing.T) {
+	for name, newPolicy := range testPolicies {
+		p := newPolicy(10)
+		for i := 0; i < 5; i++ {
+			p.Add(i)
+		}
+		p.Add(0)
+		p.Access(1)
+		p.Remove(2)
+		p.Remove(100)
+
+		keys := evictAll(p)
+		assert.ElementsMatch(t, []interface{}{0, 1, 3, 4}, keys, name)
+
+		p.Add(1)
+		p.Clear()
+		_, ok := p.Evict()
+		assert.False(t, ok, name)
+	}
+}
+
+func TestLRUPolicy(t *testing.T) {
+	p := NewLRUPolicy(3)
+	p.Add(1)
+	p.Add(2)
+	p.Add(3)
+	p.Access(1)
+	p.Demote(3)
+	assert.Equal(t, []interface{}{3, 2, 1}, evictAll(p))
+}
+
+func TestFIFOPolicy(t *testing.T) {
+	p := NewFIFOPolicy(3)
+	p.Add(1)
+	p.Add(2)
+	p.Add(3)
+	// 访问不改变顺序
+	p.Access(1)
+	p.Demote(3)
+	assert.Equal(t, []interface{}{3, 1, 2}, evictAll(p))
+}
+
+func TestLFUPolicy(t *testing.T) {
+	p := NewLFUPolicy(3)
+	p.Add(1)
+	p.Add(2)
+	p.Add(3)
+	p.Access(1)
+	p.Access(1)
+	p.Access(3)
+	// 次数相同时淘汰最久未使用的
+	assert.Equal(t, []interface{}{2, 3, 1}, evictAll(p))
+
+	p.Add(1)
+	p.Access(1)
+	p.Add(2)
+	p.Demote(1)
+	assert.Equal(t, []interface{}{1, 2}, evictAll(p))
+}
+
+func TestARCPolicy(t *testing.T) {
+	p := NewARCPolicy(4).(*arcPolicy)
+	p.Add(1)
+	p.Add(2)
+	// 再次访问进入t2
+	p.Access(1)
+	assert.True(t, p.t2.Contains(1))
+
+	p.Add(3)
+	key, ok := p.Evict()
+	assert.True(t, ok)
+	assert.Equal(t, 2, key)
+	assert.True(t, p.b1.Contains(2))
+
+	// b1幽灵命中，增大t1的目标长度，直接进入t2
+	p.Add(2)
+	assert.Equal(t, 1, p.p)
+	assert.True(t, p.t2.Contains(2))
+	assert.False(t, p.b1.Contains(2))
+
+	// 删除的key不进入幽灵链表
+	p.Remove(3)
+	assert.False(t, p.b1.Contains(3))
+
+	p.Clear()
+	assert.Equal(t, 0, p.p)
+	assert.Equal(t, 0, p.b1.Len())
+}
+
+func TestTwoQueuePolicy(t *testing.T) {
+	p := NewTwoQueuePolicy(8).(*twoQueuePolicy)
+	for i := 0; i < 4; i++ {
+		p.Add(i)
+	}
+	// a1in超过容量，淘汰最早进入的key
+	key, ok := p.Evict()
+	assert.True(t, ok)
+	assert.Equal(t, 0, key)
+	assert.True(t, p.a1out.Contains(0))
+
+	// a1out中的key再次加入，进入am
+	p.Add(0)
+	assert.True(t, p.am.Contains(0))
+
+	// 只访问一次的key先于am淘汰
+	for i := 100; i < 110; i++ {
+		p.Add(i)
+		p.Evict()
+	}
+	assert.True(t, p.am.Contains(0))
+}
+
+func TestLRUCacheWithPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	for name, newPolicy := range testPolicies {
+		cache := NewLRUCacheWithPolicy(10, time.Millisecond*50, newPolicy)
+		assert.Implements(t, (*cachex.Storage)(nil), cache, name)
+
+		for i := 0; i < 20; i++ {
+			err := cache.Set(ctx, i, i*i)
+			assert.NoError(t, err, name)
+		}
+		assert.Equal(t, 10, cache.Len(), name)
+
+		// 覆盖写入不增加数量
+		err := cache.Set(ctx, 19, 0)
+		assert.NoError(t, err, name)
+		assert.Equal(t, 10, cache.Len(), name)
+
+		var value int
+		err = cache.Get(ctx, 19, &value)
+		assert.NoError(t, err, name)
+		assert.Equal(t, 0, value, name)
+
+		// 过期返回过期数据
+		time.Sleep(time.Millisecond * 60)
+		err = cache.Get(ctx, 19, &value)
+		assert.Implements(t, (*cachex.Expired)(nil), err, name)
+		assert.Equal(t, 0, value, name)
+
+		// 过期数据先于新数据淘汰
+		err = cache.Set(ctx, "new", 1)
+		assert.NoError(t, err, name)
+		err = cache.Get(ctx, 19, &value)
+		assert.Implements(t, (*cachex.NotFound)(nil), err, name)
+
+		err = cache.Del(ctx, "new")
+		assert.NoError(t, err, name)
+		err = cache.Get(ctx, "new", &value)
+		assert.Implements(t, (*cachex.NotFound)(nil), err, name)
+		assert.Equal(t, 9, cache.Len(), name)
+
+		err = cache.Clear(ctx)
+		assert.NoError(t, err, name)
+		assert.Equal(t, 0, cache.Len(), name)
+
+		for i := 0; i < 20; i++ {
+			err := cache.Set(ctx, i, i)
+			assert.NoError(t, err, name)
+		}
+		assert.Equal(t, 10, cache.Len(), name)
+	}
+}
+
+func TestShardedLRUCacheWithPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	cache := NewShardedLRUCacheWithPolicy(4, 40, 0, NewARCPolicy)
+	for i := 0; i < 100; i++ {
+		err := cache.Set(ctx, i, i)
+		assert.NoError(t, err)
+	}
+	assert.True(t, cache.Len() <= 40)
+
+	var value int
+	err := cache.Get(ctx, 99, &value)
+	assert.NoError(t, err)
+	assert.Equal(t, 99, value)
+}
@@ -0,0 +1,343 @@
+/*
+ * 分片LRU缓存
+ * key按哈希分布到多个独立加锁的LRUCache，减少锁竞争
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package lrucache
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"runtime"
+	"time"
+
+	"github.com/wencan/cachex"
+)
+
+// ShardedLRUCache 分片的本地LRU缓存类，接口同LRUCache。
+// key按哈希分布到多个分片，每个分片是独立加锁的LRUCache，容量为总容量平均到每个分片。
+// LRU淘汰在分片内进行，因此淘汰的不一定是全局最久未使用的数据
+type ShardedLRUCache struct {
+	shards []*LRUCache
+
+	// keyFunc key规范化函数，为nil时使用cachex.CanonicalKey
+	keyFunc cachex.KeyFunc
+}
+
+// NewShardedLRUCache 新建分片的本地LRU缓存类。
+// shards为分片数，不大于0时为GOMAXPROCS的4倍，不超过maxEntries；maxEntries为总容量，为0时不限制。
+// 各分片的容量之和恰为maxEntries
+func NewShardedLRUCache(shards, maxEntries int, defaultTTL time.Duration) *ShardedLRUCache {
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0) * 4
+	}
+	if maxEntries > 0 && shards > maxEntries {
+		// 每个分片至少容纳一项，容量0表示不限制
+		shards = maxEntries
+	}
+
+	c := &ShardedLRUCache{
+		shards: make([]*LRUCache, shards),
+	}
+	for idx := range c.shards {
+		// 总容量平均到每个分片，余数分给前面的分片
+		shardEntries := 0
+		if maxEntries > 0 {
+			shardEntries = maxEntries / shards
+			if idx < maxEntries%shards {
+				shardEntries++
+			}
+		}
+		shard := NewLRUCache(shardEntries, defaultTTL)
+		// key在选择分片前已规范化
+		shard.UseKeyFunc(identityKey)
+		c.shards[idx] = shard
+	}
+	return c
+}
+
+// NewShardedLRUCacheWithPolicy 新建使用指定淘汰策略的分片本地缓存类，每个分片独立的淘汰策略。见NewLRUCacheWithPolicy
+func NewShardedLRUCacheWithPolicy(shards, maxEntries int, defaultTTL time.Duration, newPolicy PolicyFunc) *ShardedLRUCache {
+	c := NewShardedLRUCache(shards, maxEntries, defaultTTL)
+	for _, shard := range c.shards {
+		shard.policy = newPolicy(shard.MaxEntries)
+	}
+	return c
+}
+
+// identityKey 原样返回key
+func identityKey(key interface{}) (interface{}, error) {
+	return key, nil
+}
+
+// UseTTLJitter 设置写入时的TTL抖动策略，作用于每一次写入（Cachex已抖动过的TTL除外，见cachex.JitterTTL）。默认不抖动。
+func (c *ShardedLRUCache) UseTTLJitter(jitter cachex.Jitter) {
+	for _, shard := range c.shards {
+		shard.UseTTLJitter(jitter)
+	}
+}
+
+// UseKeyFunc 设置key规范化函数。默认使用cachex.CanonicalKey
+func (c *ShardedLRUCache) UseKeyFunc(keyFunc cachex.KeyFunc) {
+	c.keyFunc = keyFunc
+}
+
+// UseLease 启用租约，设置租约有效期。默认不启用。见LRUCache.UseLease
+func (c *ShardedLRUCache) UseLease(leaseTTL time.Duration) {
+	for _, shard := range c.shards {
+		shard.UseLease(leaseTTL)
+	}
+}
+
+// UseTinyLFU 每个分片使用W-TinyLFU淘汰策略代替LRU，需在使用前设置。见LRUCache.UseTinyLFU
+func (c *ShardedLRUCache) UseTinyLFU() {
+	for _, shard := range c.shards {
+		shard.UseTinyLFU()
+	}
+}
+
+// UseMaxBytes 设置全部数据的字节预算，平均到每个分片。默认不限制。见LRUCache.UseMaxBytes
+func (c *ShardedLRUCache) UseMaxBytes(maxBytes int64) {
+	shards := int64(len(c.shards))
+	for _, shard := range c.shards {
+		// 向上取整
+		shard.UseMaxBytes((maxBytes + shards - 1) / shards)
+	}
+}
+
+// UseCostFunc 设置数据字节数函数。见LRUCache.UseCostFunc
+func (c *ShardedLRUCache) UseCostFunc(costFunc CostFunc) {
+	for _, shard := range c.shards {
+		shard.UseCostFunc(costFunc)
+	}
+}
+
+// Bytes 当前全部数据的字节数。未设置字节预算时为0
+func (c *ShardedLRUCache) Bytes() int64 {
+	var bytes int64
+	for _, shard := range c.shards {
+		bytes += shard.Bytes()
+	}
+	return bytes
+}
+
+// cacheKey 返回规范化的key
+func (c *ShardedLRUCache) cacheKey(key interface{}) (interface{}, error) {
+	if c.keyFunc != nil {
+		if keyable, ok := key.(cachex.Keyable); ok {
+			key = keyable.CacheKey()
+		}
+		return c.keyFunc(key)
+	}
+	return cachex.CanonicalKey(key)
+}
+
+// shard 规范化key，返回规范化的key和所属的分片
+func (c *ShardedLRUCache) shard(key interface{}) (interface{}, *LRUCache, error) {
+	key, err := c.cacheKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, c.shards[hashKey(key)%uint64(len(c.shards))], nil
+}
+
+// hashKey 规范化的key的哈希值
+func hashKey(key interface{}) uint64 {
+	switch k := key.(type) {
+	case string:
+		// 内联fnv-1a，避免分配
+		hash := uint64(14695981039346656037)
+		for idx := 0; idx < len(k); idx++ {
+			hash ^= uint64(k[idx])
+			hash *= 1099511628211
+		}
+		return hash
+	case int:
+		return mixHash(uint64(k))
+	case int64:
+		return mixHash(uint64(k))
+	case int32:
+		return mixHash(uint64(k))
+	case uint:
+		return mixHash(uint64(k))
+	case uint64:
+		return mixHash(k)
+	case uint32:
+		return mixHash(uint64(k))
+	}
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%T:%v", key, key)
+	return h.Sum64()
+}
+
+// mixHash 打散整数的位（splitmix64的最终混合步骤），使连续的整数均匀分布
+func mixHash(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Set 设置缓存数据
+func (c *ShardedLRUCache) Set(ctx context.Context, key, value interface{}) error {
+	key, shard, err := c.shard(key)
+	if err != nil {
+		return err
+	}
+	return shard.Set(ctx, key, value)
+}
+
+// SetWithTTL 设置缓存数据，并定制TTL。覆盖已存在的数据
+func (c *ShardedLRUCache) SetWithTTL(ctx context.Context, key, value interface{}, TTL time.Duration) error {
+	key, shard, err := c.shard(key)
+	if err != nil {
+		return err
+	}
+	return shard.SetWithTTL(ctx, key, value, TTL)
+}
+
+// SetWithMode 按写入模式设置缓存数据，实现cachex.ModeWritableStorage接口。返回是否写入
+func (c *ShardedLRUCache) SetWithMode(ctx context.Context, key, value interface{}, mode cachex.WriteMode) (bool, error) {
+	key, shard, err := c.shard(key)
+	if err != nil {
+		return false, err
+	}
+	return shard.SetWithMode(ctx, key, value, mode)
+}
+
+// Add 仅数据不存在时设置缓存数据，返回是否写入
+func (c *ShardedLRUCache) Add(ctx context.Context, key, value interface{}) (bool, error) {
+	return c.SetWithMode(ctx, key, value, cachex.WriteAdd)
+}
+
+// SetWithTTLAndMode 按写入模式设置缓存数据，并定制TTL。返回是否写入。
+// 已过期的数据视为不存在
+func (c *ShardedLRUCache) SetWithTTLAndMode(ctx context.Context, key, value interface{}, TTL time.Duration, mode cachex.WriteMode) (bool, error) {
+	key, shard, err := c.shard(key)
+	if err != nil {
+		return false, err
+	}
+	return shard.SetWithTTLAndMode(ctx, key, value, TTL, mode)
+}
+
+// CompareAndSet 仅当key的版本仍为version时设置缓存数据，实现cachex.CASStorage接口。见LRUCache.CompareAndSet
+func (c *ShardedLRUCache) CompareAndSet(ctx context.Context, key, value interface{}, version uint64, TTL time.Duration) (bool, error) {
+	key, shard, err := c.shard(key)
+	if err != nil {
+		return false, err
+	}
+	return shard.CompareAndSet(ctx, key, value, version, TTL)
+}
+
+// SetWithLease 凭租约设置缓存数据，实现cachex.LeasableStorage接口。见LRUCache.SetWithLease
+func (c *ShardedLRUCache) SetWithLease(ctx context.Context, key, value interface{}, lease uint64, TTL time.Duration) (bool, error) {
+	key, shard, err := c.shard(key)
+	if err != nil {
+		return false, err
+	}
+	return shard.SetWithLease(ctx, key, value, lease, TTL)
+}
+
+// Get 获取缓存数据
+func (c *ShardedLRUCache) Get(ctx context.Context, key, value interface{}) error {
+	key, shard, err := c.shard(key)
+	if err != nil {
+		return err
+	}
+	return shard.Get(ctx, key, value)
+}
+
+// GetWithAge 获取缓存数据和数据的年龄，实现cachex.AgeableStorage接口
+func (c *ShardedLRUCache) GetWithAge(ctx context.Context, key, value interface{}) (time.Duration, error) {
+	key, shard, err := c.shard(key)
+	if err != nil {
+		return 0, err
+	}
+	return shard.GetWithAge(ctx, key, value)
+}
+
+// GetWithVersion 获取缓存数据和版本令牌，实现cachex.CASStorage接口。
+// 版本在分片内按key记录。见LRUCache.GetWithVersion
+func (c *ShardedLRUCache) GetWithVersion(ctx context.Context, key, value interface{}) (uint64, error) {
+	key, shard, err := c.shard(key)
+	if err != nil {
+		return 0, err
+	}
+	return shard.GetWithVersion(ctx, key, value)
+}
+
+// GetWithLease 获取缓存数据和租约，实现cachex.LeasableStorage接口。见LRUCache.GetWithLease
+func (c *ShardedLRUCache) GetWithLease(ctx context.Context, key, value interface{}) (uint64, error) {
+	key, shard, err := c.shard(key)
+	if err != nil {
+		return 0, err
+	}
+	return shard.GetWithLease(ctx, key, value)
+}
+
+// Remove 删除缓存数据
+func (c *ShardedLRUCache) Remove(key interface{}) {
+	key, shard, err := c.shard(key)
+	if err != nil {
+		return
+	}
+	shard.Remove(key)
+}
+
+// Del 删除缓存数据
+func (c *ShardedLRUCache) Del(ctx context.Context, keys ...interface{}) error {
+	groups := make(map[*LRUCache][]interface{})
+	for _, key := range keys {
+		key, shard, err := c.shard(key)
+		if err != nil {
+			return err
+		}
+		groups[shard] = append(groups[shard], key)
+	}
+
+	for shard, keys := range groups {
+		err := shard.Del(ctx, keys...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Len 缓存的数据的长度
+func (c *ShardedLRUCache) Len() int {
+	var length int
+	for _, shard := range c.shards {
+		length += shard.Len()
+	}
+	return length
+}
+
+// DelPattern 删除key匹配glob模式的缓存数据，实现cachex.PatternDeletableStorage接口。见LRUCache.DelPattern
+func (c *ShardedLRUCache) DelPattern(ctx context.Context, pattern string) error {
+	for _, shard := range c.shards {
+		err := shard.DelPattern(ctx, pattern)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Clear 清空缓存的数据
+func (c *ShardedLRUCache) Clear(ctx context.Context) error {
+	for _, shard := range c.shards {
+		err := shard.Clear(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package lrucache
+
+// wencan
+// 2026-10-19
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex"
+)
+
+func TestShardedLRUCache(t *testing.T) {
+	ctx := context.Background()
+	cache := NewShardedLRUCache(8, 100, time.Minute)
+	assert.Implements(t, (*cachex.DeletableStorage)(nil), cache)
+	assert.Implements(t, (*cachex.ClearableStorage)(nil), cache)
+	assert.Implements(t, (*cachex.SetWithTTLableStorage)(nil), cache)
+	assert.Implements(t, (*cachex.AgeableStorage)(nil), cache)
+	assert.Implements(t, (*cachex.ModeWritableStorage)(nil), cache)
+	assert.Implements(t, (*cachex.CASStorage)(nil), cache)
+	assert.Implements(t, (*cachex.LeasableStorage)(nil), cache)
+	assert.Implements(t, (*cachex.PatternDeletableStorage)(nil), cache)
+
+	for i := 0; i < 50; i++ {
+		err := cache.Set(ctx, i, i)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 50, cache.Len())
+	for i := 0; i < 50; i++ {
+		var value int
+		err := cache.Get(ctx, i, &value)
+		assert.NoError(t, err)
+		assert.Equal(t, i, value)
+	}
+
+	// 分布到多个分片
+	var used int
+	for _, shard := range cache.shards {
+		if shard.Len() > 0 {
+			used++
+		}
+	}
+	assert.True(t, used > 1)
+
+	err := cache.Del(ctx, 0, 1, 2, 3, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, 45, cache.Len())
+	var value int
+	err = cache.Get(ctx, 0, &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+
+	written, err := cache.Add(ctx, 5, 55)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	err = cache.Clear(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestShardedLRUCacheMaxEntries(t *testing.T) {
+	ctx := context.Background()
+	cache := NewShardedLRUCache(4, 40, time.Minute)
+
+	for i := 0; i < 1000; i++ {
+		err := cache.Set(ctx, i, i)
+		assert.NoError(t, err)
+	}
+	assert.True(t, cache.Len() <= 40)
+	for _, shard := range cache.shards {
+		assert.Equal(t, 10, shard.Len())
+	}
+
+	// 总容量不超过maxEntries
+	for _, tc := range []struct{ shards, maxEntries int }{{4, 41}, {8, 10}, {16, 3}, {0, 1}, {3, 100}} {
+		cache := NewShardedLRUCache(tc.shards, tc.maxEntries, time.Minute)
+		var total int
+		for _, shard := range cache.shards {
+			assert.True(t, shard.MaxEntries > 0)
+			total += shard.MaxEntries
+		}
+		assert.Equal(t, tc.maxEntries, total)
+
+		for i := 0; i < 1000; i++ {
+			err := cache.Set(ctx, i, i)
+			assert.NoError(t, err)
+		}
+		assert.True(t, cache.Len() <= tc.maxEntries, "shards: %d, maxEntries: %d, len: %d", tc.shards, tc.maxEntries, cache.Len())
+	}
+}
+
+func TestShardedLRUCacheKey(t *testing.T) {
+	ctx := context.Background()
+	cache := NewShardedLRUCache(4, 0, time.Minute)
+
+	type Key struct {
+		IDs []int
+	}
+	err := cache.Set(ctx, Key{IDs: []int{1, 2}}, "composite")
+	assert.NoError(t, err)
+	var value string
+	err = cache.Get(ctx, Key{IDs: []int{1, 2}}, &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "composite", value)
+
+	err = cache.Set(ctx, "user:1", "user")
+	assert.NoError(t, err)
+	err = cache.Set(ctx, "order:1", "order")
+	assert.NoError(t, err)
+	err = cache.DelPattern(ctx, "user:*")
+	assert.NoError(t, err)
+	err = cache.Get(ctx, "user:1", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	err = cache.Get(ctx, "order:1", &value)
+	assert.NoError(t, err)
+
+	// 自定义key规范化函数
+	cache.UseKeyFunc(func(key interface{}) (interface{}, error) {
+		return strings.ToLower(key.(string)), nil
+	})
+	err = cache.Set(ctx, "KEY", "lower")
+	assert.NoError(t, err)
+	err = cache.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "lower", value)
+}
+
+func TestShardedLRUCacheCachexCompareAndSet(t *testing.T) {
+	ctx := context.Background()
+	cache := NewShardedLRUCache(4, 10, time.Minute)
+
+	var c *cachex.Cachex
+	query := func(ctx context.Context, key, value interface{}) error {
+		// 查询期间数据被更新并失效
+		err := c.Del(ctx, key)
+		assert.NoError(t, err)
+
+		*value.(*string) = "stale"
+		return nil
+	}
+	c = cachex.NewCachex(cache, cachex.QueryFunc(query))
+
+	var value string
+	err := c.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "stale", value)
+
+	// 过时的查询结果没有写入
+	assert.Equal(t, 0, cache.Len())
+}
+
+// benchValue 基准测试的缓存值，复制有一定开销
+type benchValue struct {
+	ID    int
+	Name  string
+	Tags  []string
+	Attrs map[string]string
+}
+
+// benchmarkGetParallel 并发读取预先写入的数据
+func benchmarkGetParallel(b *testing.B, storage cachex.Storage) {
+	ctx := context.Background()
+	const keys = 1024
+	for i := 0; i < keys; i++ {
+		err := storage.Set(ctx, i, benchValue{
+			ID:    i,
+			Name:  strconv.Itoa(i),
+			Tags:  []string{"a", "b", "c"},
+			Attrs: map[string]string{"k": "v"},
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var value benchValue
+		i := 0
+		for pb.Next() {
+			err := storage.Get(ctx, i%keys, &value)
+			if err != nil {
+				b.Fatal(err)
+			}
+			i++
+		}
+	})
+}
+
+// benchmarkMixedParallel 并发读写，读写比为9:1
+func benchmarkMixedParallel(b *testing.B, storage cachex.Storage) {
+	ctx := context.Background()
+	const keys = 1024
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		var value int
+		i := 0
+		for pb.Next() {
+			if i%10 == 0 {
+				err := storage.Set(ctx, i%keys, i)
+				if err != nil {
+					b.Fatal(err)
+				}
+			} else {
+				_ = storage.Get(ctx, i%keys, &value)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkLRUCacheGetParallel(b *testing.B) {
+	benchmarkGetParallel(b, NewLRUCache(0, time.Hour))
+}
+
+func BenchmarkShardedLRUCacheGetParallel(b *testing.B) {
+	benchmarkGetParallel(b, NewShardedLRUCache(0, 0, time.Hour))
+}
+
+func BenchmarkLRUCacheMixedParallel(b *testing.B) {
+	benchmarkMixedParallel(b, NewLRUCache(512, time.Hour))
+}
+
+func BenchmarkShardedLRUCacheMixedParallel(b *testing.B) {
+	benchmarkMixedParallel(b, NewShardedLRUCache(0, 512, time.Hour))
+}
+
+func TestShardedLRUCacheMaxBytes(t *testing.T) {
+	ctx := context.Background()
+	cache := NewShardedLRUCache(4, 0, time.Minute)
+	cache.UseMaxBytes(4000)
+
+	for i := 0; i < 100; i++ {
+		err := cache.Set(ctx, i, sizedValue{Data: make([]byte, 100)})
+		assert.NoError(t, err)
+	}
+	assert.True(t, cache.Bytes() <= 4000)
+	assert.Equal(t, int64(cache.Len()*100), cache.Bytes())
+}
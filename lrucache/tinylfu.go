@@ -0,0 +1,284 @@
+/*
+ * W-TinyLFU淘汰策略
+ * 新数据先进入小的准入窗口LRU，离开窗口后与主LRU的淘汰候选比较访问频率，频率高的留下。
+ * 访问频率由定期衰减的count-min sketch估算，一次性的扫描不会挤出热点数据。
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package lrucache
+
+import (
+	"container/list"
+)
+
+const (
+	// sketchDepth count-min sketch的行数
+	sketchDepth = 4
+	// sketchMaxCount 计数器的上限
+	sketchMaxCount = 15
+	// minSketchWidth count-min sketch的最小列数
+	minSketchWidth = 64
+)
+
+// sketchSeeds 每行的哈希种子
+var sketchSeeds = [sketchDepth]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325}
+
+// countMinSketch 估算访问频率的count-min sketch。
+// 计数总数达到采样数时全部计数减半（衰减），使频率反映近期的访问
+type countMinSketch struct {
+	rows [sketchDepth][]uint8
+	mask uint64
+
+	// additions 上次衰减以来的计数次数
+	additions int
+	// sampleSize 衰减的采样数
+	sampleSize int
+}
+
+// newCountMinSketch 新建count-min sketch。capacity为缓存容量
+func newCountMinSketch(capacity int) *countMinSketch {
+	width := minSketchWidth
+	for width < capacity {
+		width <<= 1
+	}
+
+	s := &countMinSketch{
+		mask:       uint64(width - 1),
+		sampleSize: width * 10,
+	}
+	for idx := range s.rows {
+		s.rows[idx] = make([]uint8, width)
+	}
+	return s
+}
+
+// index 第row行的列下标
+func (s *countMinSketch) index(hash uint64, row int) uint64 {
+	return mixHash(hash^sketchSeeds[row]) & s.mask
+}
+
+// Increment 增加计数
+func (s *countMinSketch) Increment(hash uint64) {
+	for row := range s.rows {
+		idx := s.index(hash, row)
+		if s.rows[row][idx] < sketchMaxCount {
+			s.rows[row][idx]++
+		}
+	}
+
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.reset()
+	}
+}
+
+// Estimate 估算的访问频率，各行计数的最小值
+func (s *countMinSketch) Estimate(hash uint64) uint8 {
+	min := uint8(sketchMaxCount)
+	for row := range s.rows {
+		if count := s.rows[row][s.index(hash, row)]; count < min {
+			min = count
+		}
+	}
+	return min
+}
+
+// reset 全部计数减半
+func (s *countMinSketch) reset() {
+	for row := range s.rows {
+		for idx := range s.rows[row] {
+			s.rows[row][idx] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+// clear 清零
+func (s *countMinSketch) clear() {
+	for row := range s.rows {
+		for idx := range s.rows[row] {
+			s.rows[row][idx] = 0
+		}
+	}
+	s.additions = 0
+}
+
+// tinyLFU 分段
+const (
+	segmentWindow = iota
+	segmentProbation
+	segmentProtected
+)
+
+// tinyLFUEntry 淘汰顺序中的key
+type tinyLFUEntry struct {
+	key     interface{}
+	hash    uint64
+	segment int
+}
+
+// tinyLFU W-TinyLFU淘汰策略，实现EvictionPolicy接口。
+// 容量的1%为准入窗口LRU，其余为分段的主LRU：试用段和保护段（主LRU的80%）。
+// 试用段中再次访问的key升入保护段，保护段超出容量时降回试用段
+type tinyLFU struct {
+	sketch *countMinSketch
+
+	windowCapacity    int
+	protectedCapacity int
+
+	window    *list.List
+	probation *list.List
+	protected *list.List
+
+	elements map[interface{}]*list.Element
+}
+
+// NewTinyLFUPolicy 新建W-TinyLFU淘汰策略。见LRUCache.UseTinyLFU
+func NewTinyLFUPolicy(capacity int) EvictionPolicy {
+	return newTinyLFU(capacity)
+}
+
+// newTinyLFU 新建W-TinyLFU淘汰策略。capacity为缓存容量
+func newTinyLFU(capacity int) *tinyLFU {
+	windowCapacity := capacity / 100
+	if windowCapacity < 1 {
+		windowCapacity = 1
+	}
+	protectedCapacity := (capacity - windowCapacity) * 8 / 10
+
+	return &tinyLFU{
+		sketch:            newCountMinSketch(capacity),
+		windowCapacity:    windowCapacity,
+		protectedCapacity: protectedCapacity,
+		window:            list.New(),
+		probation:         list.New(),
+		protected:         list.New(),
+		elements:          make(map[interface{}]*list.Element),
+	}
+}
+
+// list 分段对应的链表
+func (p *tinyLFU) list(segment int) *list.List {
+	switch segment {
+	case segmentWindow:
+		return p.window
+	case segmentProbation:
+		return p.probation
+	default:
+		return p.protected
+	}
+}
+
+// Add 加入新的key。key进入准入窗口，窗口超出容量时，最久未使用的key进入试用段等待比较
+func (p *tinyLFU) Add(key interface{}) {
+	if _, ok := p.elements[key]; ok {
+		p.Access(key)
+		return
+	}
+
+	hash := hashKey(key)
+	p.sketch.Increment(hash)
+	p.elements[key] = p.window.PushFront(&tinyLFUEntry{key: key, hash: hash, segment: segmentWindow})
+
+	for p.window.Len() > p.windowCapacity {
+		elem := p.window.Back()
+		p.window.Remove(elem)
+		entry := elem.Value.(*tinyLFUEntry)
+		entry.segment = segmentProbation
+		p.elements[entry.key] = p.probation.PushFront(entry)
+	}
+}
+
+// Access 记录key的访问
+func (p *tinyLFU) Access(key interface{}) {
+	elem, ok := p.elements[key]
+	if !ok {
+		return
+	}
+	entry := elem.Value.(*tinyLFUEntry)
+	p.sketch.Increment(entry.hash)
+
+	switch entry.segment {
+	case segmentWindow:
+		p.window.MoveToFront(elem)
+	case segmentProbation:
+		// 升入保护段
+		p.probation.Remove(elem)
+		entry.segment = segmentProtected
+		p.elements[key] = p.protected.PushFront(entry)
+
+		for p.protected.Len() > p.protectedCapacity && p.protected.Len() > 0 {
+			// 降回试用段
+			back := p.protected.Back()
+			p.protected.Remove(back)
+			demoted := back.Value.(*tinyLFUEntry)
+			demoted.segment = segmentProbation
+			p.elements[demoted.key] = p.probation.PushFront(demoted)
+		}
+	case segmentProtected:
+		p.protected.MoveToFront(elem)
+	}
+}
+
+// Demote 降低key的保留优先级，用于已过期的数据：移到试用段的末尾，最先被淘汰
+func (p *tinyLFU) Demote(key interface{}) {
+	elem, ok := p.elements[key]
+	if !ok {
+		return
+	}
+	entry := elem.Value.(*tinyLFUEntry)
+	p.list(entry.segment).Remove(elem)
+	entry.segment = segmentProbation
+	p.elements[key] = p.probation.PushBack(entry)
+}
+
+// Evict 选出并移除下一个淘汰的key。
+// 试用段中最近进入的key（候选）与最久未使用的key（受害者）比较访问频率，淘汰频率低的；
+// 频率相同时淘汰候选，保留已在主LRU中的key
+func (p *tinyLFU) Evict() (interface{}, bool) {
+	key, ok := p.victim()
+	if ok {
+		p.Remove(key)
+	}
+	return key, ok
+}
+
+// victim 返回下一个应淘汰的key，不移除
+func (p *tinyLFU) victim() (interface{}, bool) {
+	if p.probation.Len() >= 2 {
+		candidate := p.probation.Front().Value.(*tinyLFUEntry)
+		victim := p.probation.Back().Value.(*tinyLFUEntry)
+		if p.sketch.Estimate(candidate.hash) > p.sketch.Estimate(victim.hash) {
+			return victim.key, true
+		}
+		return candidate.key, true
+	}
+
+	for _, l := range []*list.List{p.probation, p.protected, p.window} {
+		if elem := l.Back(); elem != nil {
+			return elem.Value.(*tinyLFUEntry).key, true
+		}
+	}
+	return nil, false
+}
+
+// Remove 移除key，不影响访问频率
+func (p *tinyLFU) Remove(key interface{}) {
+	elem, ok := p.elements[key]
+	if !ok {
+		return
+	}
+	delete(p.elements, key)
+	p.list(elem.Value.(*tinyLFUEntry).segment).Remove(elem)
+}
+
+// Clear 移除全部key，并清零访问频率
+func (p *tinyLFU) Clear() {
+	p.window.Init()
+	p.probation.Init()
+	p.protected.Init()
+	p.elements = make(map[interface{}]*list.Element)
+	p.sketch.clear()
+}
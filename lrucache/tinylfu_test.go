@@ -0,0 +1,182 @@
+package lrucache
+
+// wencan
+// 2026-10-19
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex"
+)
+
+func TestCountMinSketch(t *testing.T) {
+	s := newCountMinSketch(100)
+
+	for i := 0; i < 10; i++ {
+		s.Increment(hashKey("hot"))
+	}
+	s.Increment(hashKey("cold"))
+	assert.Equal(t, uint8(10), s.Estimate(hashKey("hot")))
+	assert.Equal(t, uint8(1), s.Estimate(hashKey("cold")))
+	assert.Equal(t, uint8(0), s.Estimate(hashKey("none")))
+
+	// 计数达到上限
+	for i := 0; i < 20; i++ {
+		s.Increment(hashKey("hot"))
+	}
+	assert.Equal(t, uint8(sketchMaxCount), s.Estimate(hashKey("hot")))
+
+	// 衰减
+	for i := 0; i < s.sampleSize && s.Estimate(hashKey("hot")) == sketchMaxCount; i++ {
+		s.Increment(hashKey(rand.Int()))
+	}
+	assert.True(t, s.Estimate(hashKey("hot")) < sketchMaxCount)
+	assert.True(t, s.Estimate(hashKey("hot")) >= sketchMaxCount/2-1)
+
+	s.clear()
+	assert.Equal(t, uint8(0), s.Estimate(hashKey("hot")))
+}
+
+func TestTinyLFU(t *testing.T) {
+	p := newTinyLFU(100)
+
+	for i := 0; i < 100; i++ {
+		p.Add(i)
+	}
+	// 热点数据
+	for n := 0; n < 3; n++ {
+		for i := 0; i < 50; i++ {
+			p.Access(i)
+		}
+	}
+
+	// 新数据频率低于主LRU的受害者，淘汰新数据
+	p.Add("scan")
+	p.Add("scan2")
+	victim, ok := p.Evict()
+	assert.True(t, ok)
+	assert.NotContains(t, []interface{}{0, 1, 2}, victim)
+	_, ok = p.elements[victim]
+	assert.False(t, ok)
+
+	// 过期的数据最先淘汰
+	p.Demote(10)
+	for p.probation.Len() > 1 {
+		key, _ := p.victim()
+		if key == 10 {
+			break
+		}
+		p.Remove(key)
+	}
+	victim, _ = p.Evict()
+	assert.Equal(t, 10, victim)
+
+	p.Clear()
+	_, ok = p.Evict()
+	assert.False(t, ok)
+}
+
+func TestLRUCacheTinyLFU(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRUCache(10, time.Millisecond*50)
+	cache.UseTinyLFU()
+
+	for i := 0; i < 10; i++ {
+		err := cache.Set(ctx, i, i)
+		assert.NoError(t, err)
+	}
+	// 热点数据
+	var value int
+	for n := 0; n < 3; n++ {
+		for i := 0; i < 10; i++ {
+			err := cache.Get(ctx, i, &value)
+			assert.NoError(t, err)
+		}
+	}
+
+	// 一次性扫描不挤出热点数据
+	for i := 100; i < 200; i++ {
+		err := cache.Set(ctx, i, i)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 10, cache.Len())
+	hits := 0
+	for i := 0; i < 10; i++ {
+		if cache.Get(ctx, i, &value) == nil {
+			hits++
+		}
+	}
+	assert.True(t, hits >= 8, "hits: %d", hits)
+
+	// 过期
+	time.Sleep(time.Millisecond * 60)
+	err := cache.Get(ctx, 0, &value)
+	assert.Implements(t, (*cachex.Expired)(nil), err)
+	assert.Equal(t, 0, value)
+
+	err = cache.Del(ctx, 0)
+	assert.NoError(t, err)
+	err = cache.Get(ctx, 0, &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+
+	err = cache.Clear(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, cache.Len())
+	err = cache.Set(ctx, 1, 1)
+	assert.NoError(t, err)
+	err = cache.Get(ctx, 1, &value)
+	assert.NoError(t, err)
+}
+
+// skewedTrace 偏斜的合成访问序列：zipf分布的热点访问，穿插一次性的扫描
+func skewedTrace(length int) []int {
+	rnd := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(rnd, 1.1, 1, 10000)
+
+	trace := make([]int, 0, length)
+	scan := 1000000
+	for len(trace) < length {
+		if len(trace)%5000 == 0 {
+			// 批处理遍历全部用户
+			for i := 0; i < 2000; i++ {
+				trace = append(trace, scan)
+				scan++
+			}
+		}
+		trace = append(trace, int(zipf.Uint64()))
+	}
+	return trace
+}
+
+// hitRatio 按访问序列读取，未命中时写入，返回命中率
+func hitRatio(cache *LRUCache, trace []int) float64 {
+	ctx := context.Background()
+	hits := 0
+	for _, key := range trace {
+		var value int
+		if cache.Get(ctx, key, &value) == nil {
+			hits++
+		} else {
+			_ = cache.Set(ctx, key, key)
+		}
+	}
+	return float64(hits) / float64(len(trace))
+}
+
+func TestLRUCacheTinyLFUHitRatio(t *testing.T) {
+	trace := skewedTrace(200000)
+
+	lru := NewLRUCache(500, time.Hour)
+	lruRatio := hitRatio(lru, trace)
+
+	tinyLFU := NewLRUCache(500, time.Hour)
+	tinyLFU.UseTinyLFU()
+	tinyLFURatio := hitRatio(tinyLFU, trace)
+
+	t.Logf("hit ratio: lru %.4f, w-tinylfu %.4f", lruRatio, tinyLFURatio)
+	assert.True(t, tinyLFURatio > lruRatio)
+}
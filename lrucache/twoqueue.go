@@ -0,0 +1,93 @@
+/*
+ * 2Q淘汰策略
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package lrucache
+
+const (
+	// twoQueueInRatio 2Q的a1in队列占容量的比例
+	twoQueueInRatio = 0.25
+	// twoQueueOutRatio 2Q的a1out幽灵队列占容量的比例
+	twoQueueOutRatio = 0.5
+)
+
+// twoQueuePolicy 2Q淘汰策略。
+// 新的key进入FIFO队列a1in，从a1in淘汰的key进入幽灵队列a1out（只有key没有数据）；
+// 在a1out中的key再次加入时进入LRU队列am。只访问一次的key不会进入am，避免挤出热点数据
+type twoQueuePolicy struct {
+	inCapacity  int
+	outCapacity int
+
+	a1in  *keyList
+	a1out *keyList
+	am    *keyList
+}
+
+// NewTwoQueuePolicy 新建2Q淘汰策略。a1in为容量的25%，a1out为容量的50%。capacity为0时按1处理
+func NewTwoQueuePolicy(capacity int) EvictionPolicy {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &twoQueuePolicy{
+		inCapacity:  maxInt(int(float64(capacity)*twoQueueInRatio), 1),
+		outCapacity: maxInt(int(float64(capacity)*twoQueueOutRatio), 1),
+		a1in:        newKeyList(),
+		a1out:       newKeyList(),
+		am:          newKeyList(),
+	}
+}
+
+// Add 实现EvictionPolicy接口
+func (p *twoQueuePolicy) Add(key interface{}) {
+	if p.a1in.Contains(key) || p.am.Contains(key) {
+		p.Access(key)
+		return
+	}
+
+	if p.a1out.Remove(key) {
+		p.am.PushFront(key)
+		return
+	}
+	p.a1in.PushFront(key)
+}
+
+// Access 实现EvictionPolicy接口。a1in中的key访问不改变顺序
+func (p *twoQueuePolicy) Access(key interface{}) {
+	p.am.MoveToFront(key)
+}
+
+// Demote 实现EvictionPolicy接口，移到所在队列的队尾
+func (p *twoQueuePolicy) Demote(key interface{}) {
+	p.a1in.MoveToBack(key)
+	p.am.MoveToBack(key)
+}
+
+// Evict 实现EvictionPolicy接口。a1in超过容量时从a1in淘汰，淘汰的key进入a1out；否则从am淘汰
+func (p *twoQueuePolicy) Evict() (interface{}, bool) {
+	if p.a1in.Len() > 0 && (p.a1in.Len() > p.inCapacity || p.am.Len() == 0) {
+		key, _ := p.a1in.PopBack()
+		p.a1out.PushFront(key)
+		for p.a1out.Len() > p.outCapacity {
+			p.a1out.PopBack()
+		}
+		return key, true
+	}
+	return p.am.PopBack()
+}
+
+// Remove 实现EvictionPolicy接口。被删除的key不进入a1out
+func (p *twoQueuePolicy) Remove(key interface{}) {
+	if !p.a1in.Remove(key) {
+		p.am.Remove(key)
+	}
+}
+
+// Clear 实现EvictionPolicy接口
+func (p *twoQueuePolicy) Clear() {
+	p.a1in.Clear()
+	p.a1out.Clear()
+	p.am.Clear()
+}
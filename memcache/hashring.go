@@ -0,0 +1,113 @@
+/*
+ * 一致性哈希选择memcached服务器
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package memcache
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	gomemcache "github.com/bradfitz/gomemcache/memcache"
+	"github.com/wencan/cachex/internal/hashring"
+)
+
+// DefaultReplicas 每个服务器默认的虚拟节点数
+const DefaultReplicas = hashring.DefaultReplicas
+
+// ErrNoServers 没有配置服务器
+var ErrNoServers = gomemcache.ErrNoServers
+
+// HashRing 一致性哈希环，实现gomemcache.ServerSelector接口。
+// 增删服务器时，只有少部分key改变所属的服务器。
+type HashRing struct {
+	replicas int
+
+	lock sync.RWMutex
+
+	// addrs 全部服务器地址
+	addrs []net.Addr
+
+	ring *hashring.Ring
+
+	// servers 配置的服务器地址到解析后地址的映射
+	servers map[string]net.Addr
+}
+
+// NewHashRing 新建一致性哈希环。replicas为每个服务器的虚拟节点数，不大于0时使用DefaultReplicas
+func NewHashRing(replicas int, servers ...string) (*HashRing, error) {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+
+	ring := &HashRing{
+		replicas: replicas,
+	}
+	err := ring.SetServers(servers...)
+	if err != nil {
+		return nil, err
+	}
+	return ring, nil
+}
+
+// SetServers 设置服务器。包含“/”的地址为unix socket，否则为TCP地址。
+// 地址解析失败返回错误，不改变已有的服务器
+func (r *HashRing) SetServers(servers ...string) error {
+	addrs := make([]net.Addr, len(servers))
+	for idx, server := range servers {
+		var err error
+		if strings.Contains(server, "/") {
+			addrs[idx], err = net.ResolveUnixAddr("unix", server)
+		} else {
+			addrs[idx], err = net.ResolveTCPAddr("tcp", server)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	mapping := make(map[string]net.Addr, len(servers))
+	for idx, server := range servers {
+		mapping[server] = addrs[idx]
+	}
+	// 使用配置的地址而不是解析后的地址计算哈希，域名解析变化不影响分布
+	ring := hashring.New(r.replicas, servers...)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.addrs = addrs
+	r.ring = ring
+	r.servers = mapping
+	return nil
+}
+
+// PickServer 返回key所属的服务器，实现gomemcache.ServerSelector接口
+func (r *HashRing) PickServer(key string) (net.Addr, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	server, ok := r.ring.Pick(key)
+	if !ok {
+		return nil, ErrNoServers
+	}
+	return r.servers[server], nil
+}
+
+// Each 遍历全部服务器，实现gomemcache.ServerSelector接口
+func (r *HashRing) Each(f func(net.Addr) error) error {
+	r.lock.RLock()
+	addrs := r.addrs
+	r.lock.RUnlock()
+
+	for _, addr := range addrs {
+		if err := f(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package memcache
+
+// wencan
+// 2026-10-19
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashRing(t *testing.T) {
+	ring, err := NewHashRing(0, "127.0.0.1:11211", "127.0.0.1:11212", "127.0.0.1:11213")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	picked := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		addr, err := ring.PickServer(key)
+		assert.NoError(t, err)
+		picked[key] = addr.String()
+	}
+
+	var servers []string
+	err = ring.Each(func(addr net.Addr) error {
+		servers = append(servers, addr.String())
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1:11211", "127.0.0.1:11212", "127.0.0.1:11213"}, servers)
+
+	// 增加服务器，只有少部分key改变所属的服务器
+	err = ring.SetServers("127.0.0.1:11211", "127.0.0.1:11212", "127.0.0.1:11213", "127.0.0.1:11214")
+	assert.NoError(t, err)
+	moved := 0
+	for key, server := range picked {
+		addr, err := ring.PickServer(key)
+		assert.NoError(t, err)
+		if addr.String() != server {
+			assert.Equal(t, "127.0.0.1:11214", addr.String())
+			moved++
+		}
+	}
+	assert.True(t, moved > 0 && moved < 500, "moved: %d", moved)
+
+	// 地址解析失败，不改变已有的服务器
+	err = ring.SetServers("bad address")
+	assert.Error(t, err)
+	_, err = ring.PickServer("key")
+	assert.NoError(t, err)
+
+	err = ring.SetServers()
+	assert.NoError(t, err)
+	_, err = ring.PickServer("key")
+	assert.Equal(t, ErrNoServers, err)
+}
@@ -0,0 +1,57 @@
+/*
+ * memcached key规范化
+ * memcached的key不能包含空白、控制字符，长度不能超过250字节
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package memcache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+)
+
+// MaxKeyLength memcached key的最大长度
+const MaxKeyLength = 250
+
+// sanitizeKey 返回合法的memcached key。
+// 空白、控制字符和“%”转义为“%XX”；超长的key截断，并加上“#”和完整key的sha1值，保证不同的key不冲突
+func sanitizeKey(key string) string {
+	escaped := key
+	for idx := 0; idx < len(key); idx++ {
+		if illegalKeyChar(key[idx]) {
+			escaped = escapeKey(key)
+			break
+		}
+	}
+
+	if len(escaped) <= MaxKeyLength {
+		return escaped
+	}
+	sum := sha1.Sum([]byte(escaped))
+	suffix := "#" + hex.EncodeToString(sum[:])
+	return escaped[:MaxKeyLength-len(suffix)] + suffix
+}
+
+// illegalKeyChar 需要转义的字符
+func illegalKeyChar(c byte) bool {
+	return c <= ' ' || c == 0x7f || c == '%'
+}
+
+// escapeKey 转义空白、控制字符和“%”
+func escapeKey(key string) string {
+	const hexDigits = "0123456789ABCDEF"
+
+	buf := make([]byte, 0, len(key)+8)
+	for idx := 0; idx < len(key); idx++ {
+		c := key[idx]
+		if illegalKeyChar(c) {
+			buf = append(buf, '%', hexDigits[c>>4], hexDigits[c&0x0F])
+		} else {
+			buf = append(buf, c)
+		}
+	}
+	return string(buf)
+}
@@ -0,0 +1,268 @@
+/*
+ * memcached存储支持
+ * 通过memcached文本协议读写，多个服务器按一致性哈希分布
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package memcache
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	gomemcache "github.com/bradfitz/gomemcache/memcache"
+	"github.com/wencan/cachex"
+)
+
+// NotFound 没找到错误
+type NotFound struct{}
+
+// NotFound 实现cachex.NotFound错误接口
+func (NotFound) NotFound() {}
+func (NotFound) Error() string {
+	return "not found"
+}
+
+var notFound = NotFound{}
+
+// maxRelativeExpiration memcached的相对过期时间上限，超过视为unix时间戳
+const maxRelativeExpiration = time.Hour * 24 * 30
+
+// McCache memcached缓存类，实现了cachex.DeletableStorage接口和cachex.SetWithTTLableStorage接口
+type McCache struct {
+	client *gomemcache.Client
+
+	keyPrefix string
+
+	defaultTTL time.Duration
+
+	// jitter 写入时的TTL抖动策略
+	jitter cachex.Jitter
+
+	// keyFunc key规范化函数
+	keyFunc cachex.KeyFunc
+
+	codec cachex.Codec
+}
+
+type mcOptions struct {
+	keyPrefix string
+
+	defaultTTL time.Duration
+
+	jitter cachex.Jitter
+
+	keyFunc cachex.KeyFunc
+
+	codec cachex.Codec
+
+	replicas int
+
+	timeout time.Duration
+
+	maxIdleConns int
+}
+
+// McOption memcache配置
+type McOption struct {
+	f func(*mcOptions)
+}
+
+// McKeyPrefixOption 配置key前缀
+func McKeyPrefixOption(keyPrefix string) McOption {
+	return McOption{func(options *mcOptions) {
+		options.keyPrefix = keyPrefix
+	}}
+}
+
+// McDefaultTTLOption 配置默认TTL。默认不过期
+func McDefaultTTLOption(defaultTTL time.Duration) McOption {
+	return McOption{func(options *mcOptions) {
+		options.defaultTTL = defaultTTL
+	}}
+}
+
+// McTTLJitterOption 配置写入时的TTL抖动策略，作用于每一次写入（Cachex已抖动过的TTL除外，见cachex.JitterTTL）。默认不抖动
+func McTTLJitterOption(jitter cachex.Jitter) McOption {
+	return McOption{func(options *mcOptions) {
+		options.jitter = jitter
+	}}
+}
+
+// McKeyFuncOption 配置key规范化函数，在转为字符串前调用
+func McKeyFuncOption(keyFunc cachex.KeyFunc) McOption {
+	return McOption{func(options *mcOptions) {
+		options.keyFunc = keyFunc
+	}}
+}
+
+// McCodecOption 配置编解码器。默认使用cachex.MsgpackCodec
+func McCodecOption(codec cachex.Codec) McOption {
+	return McOption{func(options *mcOptions) {
+		options.codec = codec
+	}}
+}
+
+// McReplicasOption 配置一致性哈希每个服务器的虚拟节点数，默认为DefaultReplicas。
+// 仅作用于NewMcCache
+func McReplicasOption(replicas int) McOption {
+	return McOption{func(options *mcOptions) {
+		options.replicas = replicas
+	}}
+}
+
+// McTimeoutOption 配置连接、读写的超时时间。仅作用于NewMcCache
+func McTimeoutOption(timeout time.Duration) McOption {
+	return McOption{func(options *mcOptions) {
+		options.timeout = timeout
+	}}
+}
+
+// McMaxIdleConnsOption 配置每个服务器的最大空闲连接数。仅作用于NewMcCache
+func McMaxIdleConnsOption(maxIdleConns int) McOption {
+	return McOption{func(options *mcOptions) {
+		options.maxIdleConns = maxIdleConns
+	}}
+}
+
+// NewMcCache 创建memcached缓存对象
+// servers为memcached服务器地址，key按一致性哈希分布到各个服务器
+func NewMcCache(servers []string, options ...McOption) (*McCache, error) {
+	var opts mcOptions
+	for _, option := range options {
+		option.f(&opts)
+	}
+
+	ring, err := NewHashRing(opts.replicas, servers...)
+	if err != nil {
+		return nil, err
+	}
+	client := gomemcache.NewFromSelector(ring)
+	if opts.timeout != 0 {
+		client.Timeout = opts.timeout
+	}
+	if opts.maxIdleConns != 0 {
+		client.MaxIdleConns = opts.maxIdleConns
+	}
+
+	return NewMcCacheWithClient(client, options...), nil
+}
+
+// NewMcCacheWithClient 使用已有的客户端创建memcached缓存对象
+func NewMcCacheWithClient(client *gomemcache.Client, options ...McOption) *McCache {
+	opts := mcOptions{
+		codec: cachex.MsgpackCodec{},
+	}
+	for _, option := range options {
+		option.f(&opts)
+	}
+
+	return &McCache{
+		client:     client,
+		keyPrefix:  opts.keyPrefix,
+		defaultTTL: opts.defaultTTL,
+		jitter:     opts.jitter,
+		keyFunc:    opts.keyFunc,
+		codec:      opts.codec,
+	}
+}
+
+// stringKey 将interface{} key转为合法的memcached key，并加上前缀，不支持类型返回错误
+// 结构体、切片、map等key编码为确定的字符串，见cachex.KeyString
+func (c *McCache) stringKey(key interface{}) (string, error) {
+	skey, err := cachex.KeyStringFunc(c.keyFunc, key)
+	if err != nil {
+		return "", err
+	}
+
+	if c.keyPrefix != "" {
+		skey = strings.Join([]string{c.keyPrefix, skey}, ":")
+	}
+	return sanitizeKey(skey), nil
+}
+
+// expiration 将TTL转为memcached的过期时间。
+// 0为不过期；不足1秒按1秒；超过30天转为unix时间戳
+func expiration(TTL time.Duration, now time.Time) int32 {
+	if TTL <= 0 {
+		return 0
+	}
+	if TTL > maxRelativeExpiration {
+		return int32(now.Add(TTL).Unix())
+	}
+	return int32((TTL + time.Second - 1) / time.Second)
+}
+
+// Set 设置缓存数据
+func (c *McCache) Set(ctx context.Context, key, value interface{}) error {
+	return c.SetWithTTL(ctx, key, value, c.defaultTTL)
+}
+
+// SetWithTTL 设置缓存数据，并定制TTL。覆盖已存在的数据
+func (c *McCache) SetWithTTL(ctx context.Context, key, value interface{}, TTL time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	skey, err := c.stringKey(key)
+	if err != nil {
+		return err
+	}
+
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	TTL = cachex.JitterTTL(ctx, c.jitter, TTL)
+
+	return c.client.Set(&gomemcache.Item{
+		Key:        skey,
+		Value:      data,
+		Expiration: expiration(TTL, time.Now()),
+	})
+}
+
+// Get 获取缓存数据
+func (c *McCache) Get(ctx context.Context, key, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	skey, err := c.stringKey(key)
+	if err != nil {
+		return err
+	}
+
+	item, err := c.client.Get(skey)
+	if err == gomemcache.ErrCacheMiss {
+		return notFound
+	} else if err != nil {
+		return err
+	}
+
+	return c.codec.Unmarshal(item.Value, value)
+}
+
+// Del 删除缓存数据
+func (c *McCache) Del(ctx context.Context, keys ...interface{}) error {
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		skey, err := c.stringKey(key)
+		if err != nil {
+			return err
+		}
+
+		err = c.client.Delete(skey)
+		if err != nil && err != gomemcache.ErrCacheMiss {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package memcache
+
+// wencan
+// 2026-10-19
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex"
+)
+
+func TestMcCache(t *testing.T) {
+	s := newFakeServer()
+	defer s.Close()
+
+	ctx := context.Background()
+
+	cache, err := NewMcCache([]string{s.Addr()}, McKeyPrefixOption("test"), McDefaultTTLOption(time.Minute))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Implements(t, (*cachex.DeletableStorage)(nil), cache)
+	assert.Implements(t, (*cachex.SetWithTTLableStorage)(nil), cache)
+
+	var value string
+	err = cache.Get(ctx, "key", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+
+	err = cache.Set(ctx, "key", "value")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test:key"}, s.Keys())
+	err = cache.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	// 过期
+	s.FastForward(time.Minute)
+	err = cache.Get(ctx, "key", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+
+	err = cache.SetWithTTL(ctx, "key", "ttl", time.Hour)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute+time.Hour), s.Expire("test:key"), time.Second*2)
+	s.FastForward(time.Minute)
+	err = cache.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "ttl", value)
+
+	err = cache.Del(ctx, "key", "none")
+	assert.NoError(t, err)
+	err = cache.Get(ctx, "key", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+
+	// 结构体key
+	type Key struct {
+		ID   int
+		Name string
+	}
+	err = cache.Set(ctx, Key{ID: 1, Name: "a b"}, "struct")
+	assert.NoError(t, err)
+	err = cache.Get(ctx, Key{ID: 1, Name: "a b"}, &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "struct", value)
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = cache.Get(ctx, Key{ID: 1, Name: "a b"}, &value)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestMcCacheCodec(t *testing.T) {
+	s := newFakeServer()
+	defer s.Close()
+
+	ctx := context.Background()
+
+	cache, err := NewMcCache([]string{s.Addr()}, McCodecOption(cachex.JSONCodec{}))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	type Value struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	err = cache.Set(ctx, 1, Value{ID: 1, Name: "name"})
+	assert.NoError(t, err)
+
+	var value Value
+	err = cache.Get(ctx, 1, &value)
+	assert.NoError(t, err)
+	assert.Equal(t, Value{ID: 1, Name: "name"}, value)
+
+	var raw map[string]interface{}
+	err = NewMcCacheWithClient(cache.client, McCodecOption(cachex.JSONCodec{})).Get(ctx, 1, &raw)
+	assert.NoError(t, err)
+	assert.Equal(t, "name", raw["name"])
+}
+
+func TestMcCacheKey(t *testing.T) {
+	s := newFakeServer()
+	defer s.Close()
+
+	ctx := context.Background()
+
+	cache, err := NewMcCache([]string{s.Addr()})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	keys := []string{
+		"with space",
+		"with\r\nnewline",
+		"with%20percent",
+		strings.Repeat("k", 300),
+		strings.Repeat("k", 300) + "x",
+	}
+	for idx, key := range keys {
+		err = cache.Set(ctx, key, idx)
+		assert.NoError(t, err)
+	}
+	for idx, key := range keys {
+		var value int
+		err = cache.Get(ctx, key, &value)
+		assert.NoError(t, err)
+		assert.Equal(t, idx, value)
+	}
+	assert.Len(t, s.Keys(), len(keys))
+}
+
+func TestSanitizeKey(t *testing.T) {
+	assert.Equal(t, "key", sanitizeKey("key"))
+	assert.Equal(t, "a%20b%0D%0A%25%7F", sanitizeKey("a b\r\n%\x7f"))
+
+	long := sanitizeKey(strings.Repeat("k", 300))
+	assert.Len(t, long, MaxKeyLength)
+	assert.True(t, strings.HasPrefix(long, strings.Repeat("k", 100)))
+	assert.NotEqual(t, long, sanitizeKey(strings.Repeat("k", 301)))
+	assert.Equal(t, strings.Repeat("k", MaxKeyLength), sanitizeKey(strings.Repeat("k", MaxKeyLength)))
+}
+
+func TestExpiration(t *testing.T) {
+	now := time.Now()
+	assert.Equal(t, int32(0), expiration(0, now))
+	assert.Equal(t, int32(1), expiration(time.Millisecond, now))
+	assert.Equal(t, int32(60), expiration(time.Minute, now))
+	assert.Equal(t, int32(now.Add(time.Hour*24*31).Unix()), expiration(time.Hour*24*31, now))
+}
+
+func TestMcCacheServers(t *testing.T) {
+	servers := []*fakeServer{newFakeServer(), newFakeServer(), newFakeServer()}
+	addrs := make([]string, len(servers))
+	for idx, s := range servers {
+		defer s.Close()
+		addrs[idx] = s.Addr()
+	}
+
+	ctx := context.Background()
+
+	cache, err := NewMcCache(addrs)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for i := 0; i < 300; i++ {
+		err = cache.Set(ctx, i, i)
+		assert.NoError(t, err)
+	}
+	total := 0
+	for _, s := range servers {
+		// 分布到全部服务器
+		assert.NotEmpty(t, s.Keys())
+		total += len(s.Keys())
+	}
+	assert.Equal(t, 300, total)
+
+	for i := 0; i < 300; i++ {
+		var value int
+		err = cache.Get(ctx, i, &value)
+		assert.NoError(t, err)
+		assert.Equal(t, i, value)
+	}
+}
@@ -0,0 +1,209 @@
+package memcache
+
+// wencan
+// 2026-10-19
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeItem 假memcached服务器保存的数据
+type fakeItem struct {
+	value  []byte
+	flags  uint32
+	expire time.Time
+}
+
+// fakeServer 进程内的假memcached服务器，支持文本协议的get、gets、set、add、replace、delete、flush_all命令
+type fakeServer struct {
+	listener net.Listener
+
+	lock  sync.Mutex
+	items map[string]fakeItem
+	// offset 时间偏移，用于模拟时间流逝
+	offset time.Duration
+
+	wg sync.WaitGroup
+}
+
+func newFakeServer() *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	s := &fakeServer{
+		listener: listener,
+		items:    make(map[string]fakeItem),
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer conn.Close()
+				s.serve(conn)
+			}()
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *fakeServer) Close() {
+	s.listener.Close()
+}
+
+// FastForward 模拟时间流逝
+func (s *fakeServer) FastForward(d time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.offset += d
+}
+
+// Keys 未过期的key
+func (s *fakeServer) Keys() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var keys []string
+	for key := range s.items {
+		if _, ok := s.get(key); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Expire 数据的过期时间，不存在或不过期返回零值
+func (s *fakeServer) Expire(key string) time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.items[key].expire
+}
+
+func (s *fakeServer) now() time.Time {
+	return time.Now().Add(s.offset)
+}
+
+// get 获取未过期的数据。调用者需持有锁
+func (s *fakeServer) get(key string) (fakeItem, bool) {
+	item, ok := s.items[key]
+	if !ok {
+		return fakeItem{}, false
+	}
+	if !item.expire.IsZero() && !s.now().Before(item.expire) {
+		delete(s.items, key)
+		return fakeItem{}, false
+	}
+	return item, true
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			fmt.Fprint(rw, "ERROR\r\n")
+			rw.Flush()
+			continue
+		}
+
+		switch fields[0] {
+		case "get", "gets":
+			s.lock.Lock()
+			for _, key := range fields[1:] {
+				if item, ok := s.get(key); ok {
+					fmt.Fprintf(rw, "VALUE %s %d %d 0\r\n", key, item.flags, len(item.value))
+					rw.Write(item.value)
+					fmt.Fprint(rw, "\r\n")
+				}
+			}
+			s.lock.Unlock()
+			fmt.Fprint(rw, "END\r\n")
+		case "set", "add", "replace":
+			if len(fields) != 5 {
+				fmt.Fprint(rw, "ERROR\r\n")
+				break
+			}
+			flags, _ := strconv.ParseUint(fields[2], 10, 32)
+			exptime, _ := strconv.ParseInt(fields[3], 10, 64)
+			size, err := strconv.Atoi(fields[4])
+			if err != nil {
+				fmt.Fprint(rw, "CLIENT_ERROR bad data chunk\r\n")
+				break
+			}
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(rw, data); err != nil {
+				return
+			}
+			fmt.Fprint(rw, s.store(fields[0], fields[1], data[:size], uint32(flags), exptime))
+		case "delete":
+			s.lock.Lock()
+			_, ok := s.get(fields[1])
+			delete(s.items, fields[1])
+			s.lock.Unlock()
+			if ok {
+				fmt.Fprint(rw, "DELETED\r\n")
+			} else {
+				fmt.Fprint(rw, "NOT_FOUND\r\n")
+			}
+		case "flush_all":
+			s.lock.Lock()
+			s.items = make(map[string]fakeItem)
+			s.lock.Unlock()
+			fmt.Fprint(rw, "OK\r\n")
+		case "version":
+			fmt.Fprint(rw, "VERSION fake\r\n")
+		default:
+			fmt.Fprint(rw, "ERROR\r\n")
+		}
+		rw.Flush()
+	}
+}
+
+// store 执行写入命令，返回响应
+func (s *fakeServer) store(cmd, key string, value []byte, flags uint32, exptime int64) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, exists := s.get(key)
+	if (cmd == "add" && exists) || (cmd == "replace" && !exists) {
+		return "NOT_STORED\r\n"
+	}
+
+	item := fakeItem{value: value, flags: flags}
+	switch {
+	case exptime < 0:
+		// 立即过期
+		delete(s.items, key)
+		return "STORED\r\n"
+	case exptime == 0:
+	case exptime <= int64(maxRelativeExpiration/time.Second):
+		item.expire = s.now().Add(time.Duration(exptime) * time.Second)
+	default:
+		item.expire = time.Unix(exptime, 0)
+	}
+	s.items[key] = item
+	return "STORED\r\n"
+}
@@ -47,3 +47,40 @@ func (mr *MockQuerierMockRecorder) Query(ctx, request, value interface{}) *gomoc
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockQuerier)(nil).Query), ctx, request, value)
 }
+
+// MockBatchQuerier is a mock of BatchQuerier interface
+type MockBatchQuerier struct {
+	ctrl     *gomock.Controller
+	recorder *MockBatchQuerierMockRecorder
+}
+
+// MockBatchQuerierMockRecorder is the mock recorder for MockBatchQuerier
+type MockBatchQuerierMockRecorder struct {
+	mock *MockBatchQuerier
+}
+
+// NewMockBatchQuerier creates a new mock instance
+func NewMockBatchQuerier(ctrl *gomock.Controller) *MockBatchQuerier {
+	mock := &MockBatchQuerier{ctrl: ctrl}
+	mock.recorder = &MockBatchQuerierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockBatchQuerier) EXPECT() *MockBatchQuerierMockRecorder {
+	return m.recorder
+}
+
+// QueryBatch mocks base method
+func (m *MockBatchQuerier) QueryBatch(ctx context.Context, requests, values []interface{}) []error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueryBatch", ctx, requests, values)
+	ret0, _ := ret[0].([]error)
+	return ret0
+}
+
+// QueryBatch indicates an expected call of QueryBatch
+func (mr *MockBatchQuerierMockRecorder) QueryBatch(ctx, requests, values interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueryBatch", reflect.TypeOf((*MockBatchQuerier)(nil).QueryBatch), ctx, requests, values)
+}
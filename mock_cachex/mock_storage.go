@@ -261,3 +261,296 @@ func (mr *MockSetWithTTLableStorageMockRecorder) SetWithTTL(ctx, key, value, TTL
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetWithTTL", reflect.TypeOf((*MockSetWithTTLableStorage)(nil).SetWithTTL), ctx, key, value, TTL)
 }
+
+// MockAgeableStorage is a mock of AgeableStorage interface
+type MockAgeableStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockAgeableStorageMockRecorder
+}
+
+// MockAgeableStorageMockRecorder is the mock recorder for MockAgeableStorage
+type MockAgeableStorageMockRecorder struct {
+	mock *MockAgeableStorage
+}
+
+// NewMockAgeableStorage creates a new mock instance
+func NewMockAgeableStorage(ctrl *gomock.Controller) *MockAgeableStorage {
+	mock := &MockAgeableStorage{ctrl: ctrl}
+	mock.recorder = &MockAgeableStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockAgeableStorage) EXPECT() *MockAgeableStorageMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method
+func (m *MockAgeableStorage) Get(ctx context.Context, key, value interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Get indicates an expected call of Get
+func (mr *MockAgeableStorageMockRecorder) Get(ctx, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockAgeableStorage)(nil).Get), ctx, key, value)
+}
+
+// Set mocks base method
+func (m *MockAgeableStorage) Set(ctx context.Context, key, value interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", ctx, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set
+func (mr *MockAgeableStorageMockRecorder) Set(ctx, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockAgeableStorage)(nil).Set), ctx, key, value)
+}
+
+// GetWithAge mocks base method
+func (m *MockAgeableStorage) GetWithAge(ctx context.Context, key, value interface{}) (time.Duration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithAge", ctx, key, value)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWithAge indicates an expected call of GetWithAge
+func (mr *MockAgeableStorageMockRecorder) GetWithAge(ctx, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithAge", reflect.TypeOf((*MockAgeableStorage)(nil).GetWithAge), ctx, key, value)
+}
+
+// MockPatternDeletableStorage is a mock of PatternDeletableStorage interface
+type MockPatternDeletableStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockPatternDeletableStorageMockRecorder
+}
+
+// MockPatternDeletableStorageMockRecorder is the mock recorder for MockPatternDeletableStorage
+type MockPatternDeletableStorageMockRecorder struct {
+	mock *MockPatternDeletableStorage
+}
+
+// NewMockPatternDeletableStorage creates a new mock instance
+func NewMockPatternDeletableStorage(ctrl *gomock.Controller) *MockPatternDeletableStorage {
+	mock := &MockPatternDeletableStorage{ctrl: ctrl}
+	mock.recorder = &MockPatternDeletableStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockPatternDeletableStorage) EXPECT() *MockPatternDeletableStorageMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method
+func (m *MockPatternDeletableStorage) Get(ctx context.Context, key, value interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Get indicates an expected call of Get
+func (mr *MockPatternDeletableStorageMockRecorder) Get(ctx, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockPatternDeletableStorage)(nil).Get), ctx, key, value)
+}
+
+// Set mocks base method
+func (m *MockPatternDeletableStorage) Set(ctx context.Context, key, value interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", ctx, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set
+func (mr *MockPatternDeletableStorageMockRecorder) Set(ctx, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockPatternDeletableStorage)(nil).Set), ctx, key, value)
+}
+
+// DelPattern mocks base method
+func (m *MockPatternDeletableStorage) DelPattern(ctx context.Context, pattern string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DelPattern", ctx, pattern)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DelPattern indicates an expected call of DelPattern
+func (mr *MockPatternDeletableStorageMockRecorder) DelPattern(ctx, pattern interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DelPattern", reflect.TypeOf((*MockPatternDeletableStorage)(nil).DelPattern), ctx, pattern)
+}
+
+// MockCASStorage is a mock of CASStorage interface
+type MockCASStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockCASStorageMockRecorder
+}
+
+// MockCASStorageMockRecorder is the mock recorder for MockCASStorage
+type MockCASStorageMockRecorder struct {
+	mock *MockCASStorage
+}
+
+// NewMockCASStorage creates a new mock instance
+func NewMockCASStorage(ctrl *gomock.Controller) *MockCASStorage {
+	mock := &MockCASStorage{ctrl: ctrl}
+	mock.recorder = &MockCASStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockCASStorage) EXPECT() *MockCASStorageMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method
+func (m *MockCASStorage) Get(ctx context.Context, key, value interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Get indicates an expected call of Get
+func (mr *MockCASStorageMockRecorder) Get(ctx, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockCASStorage)(nil).Get), ctx, key, value)
+}
+
+// Set mocks base method
+func (m *MockCASStorage) Set(ctx context.Context, key, value interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", ctx, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set
+func (mr *MockCASStorageMockRecorder) Set(ctx, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockCASStorage)(nil).Set), ctx, key, value)
+}
+
+// GetWithVersion mocks base method
+func (m *MockCASStorage) GetWithVersion(ctx context.Context, key, value interface{}) (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithVersion", ctx, key, value)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWithVersion indicates an expected call of GetWithVersion
+func (mr *MockCASStorageMockRecorder) GetWithVersion(ctx, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithVersion", reflect.TypeOf((*MockCASStorage)(nil).GetWithVersion), ctx, key, value)
+}
+
+// CompareAndSet mocks base method
+func (m *MockCASStorage) CompareAndSet(ctx context.Context, key, value interface{}, version uint64, TTL time.Duration) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompareAndSet", ctx, key, value, version, TTL)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompareAndSet indicates an expected call of CompareAndSet
+func (mr *MockCASStorageMockRecorder) CompareAndSet(ctx, key, value, version, TTL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareAndSet", reflect.TypeOf((*MockCASStorage)(nil).CompareAndSet), ctx, key, value, version, TTL)
+}
+
+// MockLeasableStorage is a mock of LeasableStorage interface
+type MockLeasableStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockLeasableStorageMockRecorder
+}
+
+// MockLeasableStorageMockRecorder is the mock recorder for MockLeasableStorage
+type MockLeasableStorageMockRecorder struct {
+	mock *MockLeasableStorage
+}
+
+// NewMockLeasableStorage creates a new mock instance
+func NewMockLeasableStorage(ctrl *gomock.Controller) *MockLeasableStorage {
+	mock := &MockLeasableStorage{ctrl: ctrl}
+	mock.recorder = &MockLeasableStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockLeasableStorage) EXPECT() *MockLeasableStorageMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method
+func (m *MockLeasableStorage) Get(ctx context.Context, key, value interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Get indicates an expected call of Get
+func (mr *MockLeasableStorageMockRecorder) Get(ctx, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockLeasableStorage)(nil).Get), ctx, key, value)
+}
+
+// Set mocks base method
+func (m *MockLeasableStorage) Set(ctx context.Context, key, value interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", ctx, key, value)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set
+func (mr *MockLeasableStorageMockRecorder) Set(ctx, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockLeasableStorage)(nil).Set), ctx, key, value)
+}
+
+// GetWithLease mocks base method
+func (m *MockLeasableStorage) GetWithLease(ctx context.Context, key, value interface{}) (uint64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWithLease", ctx, key, value)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWithLease indicates an expected call of GetWithLease
+func (mr *MockLeasableStorageMockRecorder) GetWithLease(ctx, key, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithLease", reflect.TypeOf((*MockLeasableStorage)(nil).GetWithLease), ctx, key, value)
+}
+
+// SetWithLease mocks base method
+func (m *MockLeasableStorage) SetWithLease(ctx context.Context, key, value interface{}, lease uint64, TTL time.Duration) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetWithLease", ctx, key, value, lease, TTL)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetWithLease indicates an expected call of SetWithLease
+func (mr *MockLeasableStorageMockRecorder) SetWithLease(ctx, key, value, lease, TTL interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetWithLease", reflect.TypeOf((*MockLeasableStorage)(nil).SetWithLease), ctx, key, value, lease, TTL)
+}
@@ -0,0 +1,124 @@
+/*
+ * key的glob模式匹配
+ * 语法同redis的KEYS、SCAN MATCH：*匹配任意字符串，?匹配单个字符，[abc]、[^a]、[a-z]匹配字符集合，\转义
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package cachex
+
+// MatchPattern 判断name是否匹配glob模式pattern。
+// 语法同redis的KEYS命令，格式不完整的模式也按redis的方式宽松处理：未闭合的[在模式结尾处闭合，结尾的\按字面匹配
+func MatchPattern(pattern, name string) bool {
+	// 回溯点：最近一个*在pattern中的位置，以及该*已经匹配到的name位置
+	starP, starN := -1, 0
+	p, n := 0, 0
+	for n < len(name) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				starP, starN = p, n
+				p++
+				continue
+			case '?':
+				p++
+				n++
+				continue
+			case '[':
+				matched, width := matchClass(pattern[p:], name[n])
+				if matched {
+					p += width
+					n++
+					continue
+				}
+			case '\\':
+				c := byte('\\')
+				width := 1
+				if p+1 < len(pattern) {
+					c = pattern[p+1]
+					width = 2
+				}
+				if c == name[n] {
+					p += width
+					n++
+					continue
+				}
+			default:
+				if pattern[p] == name[n] {
+					p++
+					n++
+					continue
+				}
+			}
+		}
+
+		// 不匹配，回溯到最近的*，让它多匹配一个字符
+		if starP < 0 {
+			return false
+		}
+		starN++
+		p, n = starP+1, starN
+	}
+
+	// name已匹配完，pattern剩余部分只能是*
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchClass 匹配字符集合，class以[开头。返回是否匹配，和字符集合在模式中的长度
+func matchClass(class string, c byte) (matched bool, width int) {
+	i := 1
+	negate := false
+	if i < len(class) && class[i] == '^' {
+		negate = true
+		i++
+	}
+
+	for i < len(class) && class[i] != ']' {
+		lo := class[i]
+		if lo == '\\' && i+1 < len(class) {
+			i++
+			lo = class[i]
+		}
+		i++
+
+		hi := lo
+		if i+1 < len(class) && class[i] == '-' && class[i+1] != ']' {
+			hi = class[i+1]
+			if hi == '\\' && i+2 < len(class) {
+				hi = class[i+2]
+				i++
+			}
+			i += 2
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+		}
+
+		if lo <= c && c <= hi {
+			matched = true
+		}
+	}
+
+	if i < len(class) {
+		// 跳过]
+		i++
+	}
+	return matched != negate, i
+}
+
+// EscapePattern 转义s中的glob特殊字符，使其在模式中按字面匹配
+func EscapePattern(s string) string {
+	escaped := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '*', '?', '[', ']', '\\':
+			escaped = append(escaped, '\\')
+		}
+		escaped = append(escaped, s[i])
+	}
+	return string(escaped)
+}
@@ -0,0 +1,40 @@
+package cachex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		name    string
+		matched bool
+	}{
+		{"*", "", true},
+		{"*", "user:1", true},
+		{"user:*", "user:1", true},
+		{"user:*", "users:1", false},
+		{"user:?", "user:1", true},
+		{"user:?", "user:10", false},
+		{"*:1*", "user:10", true},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "aXbYbZ", false},
+		{"user:[12]", "user:2", true},
+		{"user:[12]", "user:3", false},
+		{"user:[^12]", "user:3", true},
+		{"user:[0-9]", "user:7", true},
+		{"user:[a-z]", "user:7", false},
+		{`user:\*`, "user:*", true},
+		{`user:\*`, "user:1", false},
+		{`user:\`, `user:\`, true},
+		{"user:[12", "user:1", true},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.matched, MatchPattern(c.pattern, c.name), "%s %s", c.pattern, c.name)
+	}
+
+	assert.True(t, MatchPattern(EscapePattern("a*b?[c]")+"*", "a*b?[c]:1"))
+	assert.False(t, MatchPattern(EscapePattern("a*b"), "axb"))
+}
@@ -0,0 +1,44 @@
+/*
+ * 一致性哈希
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package peercache
+
+import "github.com/wencan/cachex/internal/hashring"
+
+// DefaultReplicas 每个节点默认的虚拟节点数
+const DefaultReplicas = hashring.DefaultReplicas
+
+// PeerPicker 节点选择接口
+type PeerPicker interface {
+	// PickPeer 返回key所属节点的地址。所属节点是本节点时，ok返回false
+	PickPeer(key string) (peer string, ok bool)
+}
+
+// HashRing 一致性哈希环，实现PeerPicker接口
+type HashRing struct {
+	self string
+
+	ring *hashring.Ring
+}
+
+// NewHashRing 新建一致性哈希环。
+// self为本节点地址，peers为全部节点地址（包括本节点），replicas为每个节点的虚拟节点数，不大于0时使用DefaultReplicas。
+func NewHashRing(self string, replicas int, peers ...string) *HashRing {
+	return &HashRing{
+		self: self,
+		ring: hashring.New(replicas, peers...),
+	}
+}
+
+// PickPeer 实现PeerPicker接口
+func (r *HashRing) PickPeer(key string) (string, bool) {
+	peer, ok := r.ring.Pick(key)
+	if !ok {
+		return "", false
+	}
+	return peer, peer != r.self
+}
@@ -0,0 +1,408 @@
+/*
+ * 节点分片缓存
+ * 每个key由一致性哈希选出的节点所有，其它节点通过HTTP从所属节点获取
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package peercache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/wencan/cachex"
+)
+
+// DefaultBasePath 默认的HTTP路径前缀
+const DefaultBasePath = "/_peercache/"
+
+// DefaultMaxBodySize 默认的写入请求体的最大字节数
+const DefaultMaxBodySize = 32 << 20
+
+// expiredHeader 标记响应的数据已过期
+const expiredHeader = "X-Peercache-Expired"
+
+// defaultContentType 编解码器未提供MIME类型时，响应使用的Content-Type
+const defaultContentType = "application/octet-stream"
+
+// contentTyper 编解码器可选实现的接口，返回编码数据的MIME类型，如cachex.MsgpackCodec、cachex.JSONCodec
+type contentTyper interface {
+	ContentType() string
+}
+
+// NotFound 没找到错误
+type NotFound struct{}
+
+// NotFound 实现cachex.NotFound错误接口
+func (NotFound) NotFound() {}
+func (NotFound) Error() string {
+	return "not found"
+}
+
+// Expired 数据已过期错误
+type Expired struct{}
+
+// Expired 实现cachex.Expired错误接口
+func (Expired) Expired() {}
+func (Expired) Error() string {
+	return "expired"
+}
+
+var notFound = NotFound{}
+var expired = Expired{}
+
+// PeerCache 节点分片缓存，实现了cachex.DeletableStorage接口和cachex.Querier接口，并作为http.Handler响应其它节点的请求。
+//
+// 作为存储后端使用时，读写转发到key的所属节点的本地存储，查询在调用方节点进行。
+// 作为查询过程使用时，查询转发到key的所属节点，由所属节点读本地存储，未命中时查询，所属节点的哨兵合并并发的重复查询。
+// 所属节点是本节点时，查询过程的request为原始key；否则为key的字符串形式（见cachex.KeyString）。
+type PeerCache struct {
+	self string
+
+	picker PeerPicker
+
+	// storage 本地存储，保存本节点所属的key
+	storage cachex.Storage
+
+	// local 本节点所属的key的查询引擎
+	local *cachex.Cachex
+
+	// newValue 新建值的指针。所属节点据此解码其它节点写入的数据，以及为其它节点查询
+	newValue func() interface{}
+
+	basePath string
+
+	client *http.Client
+
+	// codec 节点间传输数据的编解码器
+	codec cachex.Codec
+
+	// maxBodySize 写入请求体的最大字节数
+	maxBodySize int64
+}
+
+type peerOptions struct {
+	querier cachex.Querier
+
+	basePath string
+
+	client *http.Client
+
+	codec cachex.Codec
+
+	maxBodySize int64
+}
+
+// PeerOption peercache配置
+type PeerOption struct {
+	f func(*peerOptions)
+}
+
+// PeerQuerierOption 配置查询过程，所属节点未命中时调用
+func PeerQuerierOption(querier cachex.Querier) PeerOption {
+	return PeerOption{func(options *peerOptions) {
+		options.querier = querier
+	}}
+}
+
+// PeerBasePathOption 配置HTTP路径前缀，默认为DefaultBasePath
+func PeerBasePathOption(basePath string) PeerOption {
+	return PeerOption{func(options *peerOptions) {
+		options.basePath = basePath
+	}}
+}
+
+// PeerHTTPClientOption 配置访问其它节点的HTTP客户端，默认为http.DefaultClient
+func PeerHTTPClientOption(client *http.Client) PeerOption {
+	return PeerOption{func(options *peerOptions) {
+		options.client = client
+	}}
+}
+
+// PeerCodecOption 配置节点间传输数据的编解码器，全部节点需一致。默认使用cachex.MsgpackCodec
+func PeerCodecOption(codec cachex.Codec) PeerOption {
+	return PeerOption{func(options *peerOptions) {
+		options.codec = codec
+	}}
+}
+
+// PeerMaxBodySizeOption 配置其它节点写入数据时，请求体的最大字节数，默认为DefaultMaxBodySize。超出时拒绝写入
+func PeerMaxBodySizeOption(size int64) PeerOption {
+	return PeerOption{func(options *peerOptions) {
+		options.maxBodySize = size
+	}}
+}
+
+// NewPeerCache 创建节点分片缓存对象。
+// self为本节点地址（如http://10.0.0.1:8080），需与picker使用的地址一致。
+// storage为本地存储，如lrucache.LRUCache。newValue返回缓存值类型的新指针。
+func NewPeerCache(self string, picker PeerPicker, storage cachex.Storage, newValue func() interface{}, options ...PeerOption) *PeerCache {
+	opts := peerOptions{
+		basePath:    DefaultBasePath,
+		client:      http.DefaultClient,
+		codec:       cachex.MsgpackCodec{},
+		maxBodySize: DefaultMaxBodySize,
+	}
+	for _, option := range options {
+		option.f(&opts)
+	}
+
+	// 本地存储和哨兵都使用key的字符串形式，与其它节点转发的请求一致
+	local := cachex.NewCachex(storage, opts.querier)
+	local.UseKeyFunc(func(key interface{}) (interface{}, error) {
+		return cachex.KeyString(key)
+	})
+
+	return &PeerCache{
+		self:        self,
+		picker:      picker,
+		storage:     storage,
+		local:       local,
+		newValue:    newValue,
+		basePath:    opts.basePath,
+		client:      opts.client,
+		codec:       opts.codec,
+		maxBodySize: opts.maxBodySize,
+	}
+}
+
+// peerURL 返回所属节点上key的地址
+func (c *PeerCache) peerURL(peer, key string) string {
+	return strings.TrimRight(peer, "/") + c.basePath + url.PathEscape(key)
+}
+
+// pick 返回key的字符串形式，和所属的其它节点。所属节点是本节点时remote返回false
+func (c *PeerCache) pick(key interface{}) (skey, peer string, remote bool, err error) {
+	skey, err = cachex.KeyString(key)
+	if err != nil {
+		return "", "", false, err
+	}
+	peer, remote = c.picker.PickPeer(skey)
+	return skey, peer, remote, nil
+}
+
+// do 向其它节点发起请求
+func (c *PeerCache) do(ctx context.Context, method, rawurl string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawurl, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	return c.client.Do(req)
+}
+
+// fetch 从其它节点获取数据
+func (c *PeerCache) fetch(ctx context.Context, rawurl string, value interface{}) error {
+	resp, err := c.do(ctx, http.MethodGet, rawurl, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return notFound
+	default:
+		return fmt.Errorf("peercache: %s: %s", rawurl, strings.TrimSpace(string(data)))
+	}
+
+	err = c.codec.Unmarshal(data, value)
+	if err != nil {
+		return err
+	}
+	if resp.Header.Get(expiredHeader) != "" {
+		// 返回过期数据同时，返回expired错误
+		return expired
+	}
+	return nil
+}
+
+// send 向其它节点写入或删除数据
+func (c *PeerCache) send(ctx context.Context, method, rawurl string, body []byte) error {
+	resp, err := c.do(ctx, method, rawurl, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("peercache: %s: %s", rawurl, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
+
+// Get 获取缓存数据。不查询
+func (c *PeerCache) Get(ctx context.Context, key, value interface{}) error {
+	skey, peer, remote, err := c.pick(key)
+	if err != nil {
+		return err
+	}
+	if !remote {
+		return c.storage.Get(ctx, skey, value)
+	}
+	return c.fetch(ctx, c.peerURL(peer, skey), value)
+}
+
+// Set 设置缓存数据
+func (c *PeerCache) Set(ctx context.Context, key, value interface{}) error {
+	skey, peer, remote, err := c.pick(key)
+	if err != nil {
+		return err
+	}
+	if !remote {
+		return c.storage.Set(ctx, skey, value)
+	}
+
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.send(ctx, http.MethodPut, c.peerURL(peer, skey), data)
+}
+
+// Del 删除缓存数据
+func (c *PeerCache) Del(ctx context.Context, keys ...interface{}) error {
+	var locals []interface{}
+	for _, key := range keys {
+		skey, peer, remote, err := c.pick(key)
+		if err != nil {
+			return err
+		}
+		if !remote {
+			locals = append(locals, skey)
+			continue
+		}
+
+		err = c.send(ctx, http.MethodDelete, c.peerURL(peer, skey), nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(locals) == 0 {
+		return nil
+	}
+	deletable, ok := c.storage.(cachex.DeletableStorage)
+	if !ok {
+		return cachex.ErrNotSupported
+	}
+	return deletable.Del(ctx, locals...)
+}
+
+// Query 实现cachex.Querier接口。由key的所属节点读本地存储，未命中时查询
+func (c *PeerCache) Query(ctx context.Context, request, value interface{}) error {
+	skey, peer, remote, err := c.pick(request)
+	if err != nil {
+		return err
+	}
+	if !remote {
+		return c.local.Get(ctx, request, value)
+	}
+
+	err = c.fetch(ctx, c.peerURL(peer, skey)+"?load=1", value)
+	if _, ok := err.(NotFound); ok {
+		return cachex.ErrNotFound
+	}
+	return err
+}
+
+// ServeHTTP 实现http.Handler接口，响应其它节点的请求
+func (c *PeerCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, c.basePath) {
+		http.NotFound(w, r)
+		return
+	}
+	key, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), c.basePath))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		value := c.newValue()
+		var isExpired bool
+		if r.URL.Query().Get("load") != "" {
+			err = c.local.Get(ctx, key, value)
+		} else {
+			err = c.storage.Get(ctx, key, value)
+		}
+		if _, ok := err.(cachex.Expired); ok {
+			isExpired = true
+		} else if _, ok := err.(cachex.NotFound); ok || err == cachex.ErrNotFound {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		data, err := c.codec.Marshal(value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if isExpired {
+			w.Header().Set(expiredHeader, "1")
+		}
+		contentType := defaultContentType
+		if typer, ok := c.codec.(contentTyper); ok {
+			contentType = typer.ContentType()
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write(data)
+
+	case http.MethodPut:
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, c.maxBodySize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		value := c.newValue()
+		err = c.codec.Unmarshal(data, value)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = c.storage.Set(ctx, key, reflect.ValueOf(value).Elem().Interface())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		deletable, ok := c.storage.(cachex.DeletableStorage)
+		if !ok {
+			http.Error(w, cachex.ErrNotSupported.Error(), http.StatusNotImplemented)
+			return
+		}
+		err = deletable.Del(ctx, key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
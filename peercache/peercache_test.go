@@ -0,0 +1,243 @@
+package peercache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex"
+	"github.com/wencan/cachex/lrucache"
+)
+
+// newTestPeers 启动n个节点
+func newTestPeers(t *testing.T, n int, querier cachex.Querier, options ...PeerOption) ([]*PeerCache, func()) {
+	var servers []*httptest.Server
+	var addrs []string
+	handlers := make([]http.Handler, n)
+	for i := 0; i < n; i++ {
+		idx := i
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers[idx].ServeHTTP(w, r)
+		}))
+		servers = append(servers, server)
+		addrs = append(addrs, server.URL)
+	}
+
+	var caches []*PeerCache
+	for i := 0; i < n; i++ {
+		picker := NewHashRing(addrs[i], 50, addrs...)
+		cache := NewPeerCache(addrs[i], picker, lrucache.NewLRUCache(0, 0), func() interface{} {
+			return new(string)
+		}, append([]PeerOption{PeerQuerierOption(querier)}, options...)...)
+		handlers[i] = cache
+		caches = append(caches, cache)
+	}
+
+	return caches, func() {
+		for _, server := range servers {
+			server.Close()
+		}
+	}
+}
+
+func TestHashRing(t *testing.T) {
+	peers := []string{"a", "b", "c"}
+	rings := []*HashRing{NewHashRing("a", 50, peers...), NewHashRing("b", 50, peers...), NewHashRing("c", 50, peers...)}
+
+	owned := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		key := string(rune('A'+i%26)) + string(rune('a'+i/26))
+
+		// 各节点对所属节点的选择一致，且恰有一个节点认为是本节点
+		var selfs int
+		peer, _ := rings[0].PickPeer(key)
+		for _, ring := range rings {
+			p, remote := ring.PickPeer(key)
+			assert.Equal(t, peer, p)
+			if !remote {
+				selfs++
+			}
+		}
+		assert.Equal(t, 1, selfs)
+		owned[peer]++
+	}
+	assert.Len(t, owned, 3)
+}
+
+func TestPeerCacheQuerier(t *testing.T) {
+	ctx := context.Background()
+
+	var queried int64
+	querier := cachex.QueryFunc(func(ctx context.Context, request, value interface{}) error {
+		atomic.AddInt64(&queried, 1)
+		if request.(string) == "non-exists" {
+			return cachex.ErrNotFound
+		}
+		reflect.ValueOf(value).Elem().SetString("value of " + request.(string))
+		return nil
+	})
+
+	caches, closeAll := newTestPeers(t, 3, querier)
+	defer closeAll()
+
+	// 每个节点都从所属节点得到结果，所属节点只查询一次
+	var wg sync.WaitGroup
+	for _, cache := range caches {
+		c := cachex.NewCachex(cachex.NopStorage{}, cache)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				var value string
+				err := c.Get(ctx, "key", &value)
+				assert.NoError(t, err)
+				assert.Equal(t, "value of key", value)
+			}()
+		}
+		wg.Wait()
+	}
+	assert.Equal(t, int64(1), atomic.LoadInt64(&queried))
+
+	for _, cache := range caches {
+		var value string
+		err := cache.Query(ctx, "non-exists", &value)
+		assert.Equal(t, cachex.ErrNotFound, err)
+	}
+}
+
+func TestPeerCacheStorage(t *testing.T) {
+	ctx := context.Background()
+
+	caches, closeAll := newTestPeers(t, 3, nil)
+	defer closeAll()
+
+	for _, cache := range caches {
+		assert.Implements(t, (*cachex.DeletableStorage)(nil), cache)
+	}
+
+	for i, cache := range caches {
+		key := string(rune('a' + i))
+		err := cache.Set(ctx, key, "value")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		// 任意节点都能读到
+		for _, other := range caches {
+			var value string
+			err = other.Get(ctx, key, &value)
+			assert.NoError(t, err)
+			assert.Equal(t, "value", value)
+		}
+
+		// 任意节点都能删除
+		err = caches[(i+1)%len(caches)].Del(ctx, key)
+		assert.NoError(t, err)
+		for _, other := range caches {
+			var value string
+			err = other.Get(ctx, key, &value)
+			assert.Implements(t, (*cachex.NotFound)(nil), err)
+		}
+	}
+}
+
+func TestPeerCacheCodec(t *testing.T) {
+	ctx := context.Background()
+
+	caches, closeAll := newTestPeers(t, 3, nil, PeerCodecOption(cachex.JSONCodec{}))
+	defer closeAll()
+
+	for i, cache := range caches {
+		key := string(rune('a' + i))
+		err := cache.Set(ctx, key, "value")
+		if !assert.NoError(t, err) {
+			return
+		}
+		for _, other := range caches {
+			var value string
+			err = other.Get(ctx, key, &value)
+			assert.NoError(t, err)
+			assert.Equal(t, "value", value)
+		}
+	}
+}
+
+func TestPeerCacheQuerierKey(t *testing.T) {
+	ctx := context.Background()
+
+	type testKey struct {
+		ID int
+	}
+
+	var requests []interface{}
+	var lock sync.Mutex
+	querier := cachex.QueryFunc(func(ctx context.Context, request, value interface{}) error {
+		lock.Lock()
+		requests = append(requests, request)
+		lock.Unlock()
+		reflect.ValueOf(value).Elem().SetString("value")
+		return nil
+	})
+
+	caches, closeAll := newTestPeers(t, 3, querier)
+	defer closeAll()
+
+	// 所属节点是本节点时，查询过程得到原始key
+	key := testKey{ID: 1}
+	skey, err := cachex.KeyString(key)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, cache := range caches {
+		if _, remote := cache.picker.PickPeer(skey); remote {
+			continue
+		}
+		var value string
+		err = cache.Query(ctx, key, &value)
+		assert.NoError(t, err)
+		assert.Equal(t, "value", value)
+	}
+	assert.Equal(t, []interface{}{key}, requests)
+
+	// 其它节点读到所属节点的缓存，不再查询
+	for _, cache := range caches {
+		var value string
+		err = cache.Query(ctx, key, &value)
+		assert.NoError(t, err)
+		assert.Equal(t, "value", value)
+	}
+	assert.Len(t, requests, 1)
+}
+
+func TestPeerCacheServeHTTP(t *testing.T) {
+	cache := NewPeerCache("self", NewHashRing("self", 0, "self"), lrucache.NewLRUCache(0, 0), func() interface{} {
+		return new(string)
+	}, PeerCodecOption(cachex.JSONCodec{}), PeerMaxBodySizeOption(16))
+
+	w := httptest.NewRecorder()
+	cache.ServeHTTP(w, httptest.NewRequest(http.MethodPut, DefaultBasePath+"key", strings.NewReader(`"value"`)))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	// Content-Type取自编解码器
+	w = httptest.NewRecorder()
+	cache.ServeHTTP(w, httptest.NewRequest(http.MethodGet, DefaultBasePath+"key", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, `"value"`, w.Body.String())
+
+	// 请求体超出限制，拒绝写入
+	w = httptest.NewRecorder()
+	cache.ServeHTTP(w, httptest.NewRequest(http.MethodPut, DefaultBasePath+"key", strings.NewReader(`"`+strings.Repeat("x", 32)+`"`)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	var value string
+	err := cache.Get(context.Background(), "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+}
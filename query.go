@@ -22,3 +22,18 @@ type Querier interface {
 	// Query 查询。value必须是非nil指针。没找到返回NotFound错误实现
 	Query(ctx context.Context, request, value interface{}) error
 }
+
+// BatchQueryFunc 批量查询过程签名
+type BatchQueryFunc func(ctx context.Context, requests, values []interface{}) []error
+
+// QueryBatch 批量查询过程实现BatchQuerier接口
+func (fun BatchQueryFunc) QueryBatch(ctx context.Context, requests, values []interface{}) []error {
+	return fun(ctx, requests, values)
+}
+
+// BatchQuerier 批量查询接口
+type BatchQuerier interface {
+	// QueryBatch 批量查询。values为与requests一一对应的非nil指针。
+	// 返回与requests一一对应的错误，没找到的返回NotFound错误实现；全部成功可返回nil
+	QueryBatch(ctx context.Context, requests, values []interface{}) []error
+}
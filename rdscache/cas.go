@@ -0,0 +1,178 @@
+/*
+ * 比较并设置（compare-and-set）
+ * 数据带有随机的版本头，通过lua脚本比较版本并写入
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package rdscache
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// versionTokenMagic 版本头。第一个字节与压缩头的标记字节相同，msgpack不使用该字节
+var versionTokenMagic = [2]byte{compressMagic, 0xE2}
+
+// versionTokenHeaderSize 版本头长度：标记(2) + 版本(8)
+const versionTokenHeaderSize = 2 + 8
+
+// casScript 比较版本并写入
+// KEYS[1]: key
+// ARGV[1]: 版本头标记；ARGV[2]: 期望的版本（8字节）；ARGV[3]: 数据；ARGV[4]: TTL毫秒数，0为不过期
+var casScript = `
+local head = redis.call('GETRANGE', KEYS[1], 0, 9)
+local current = string.rep(string.char(0), 8)
+if string.len(head) == 10 and string.sub(head, 1, 2) == ARGV[1] then
+	current = string.sub(head, 3, 10)
+end
+if current ~= ARGV[2] then
+	return 0
+end
+if ARGV[4] == '0' then
+	redis.call('SET', KEYS[1], ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[3], 'PX', ARGV[4])
+end
+return 1
+`
+
+var casScriptSHA1 = scriptSHA1(casScript)
+
+// modeScript 按写入模式写入，删除标记和租约占位视为不存在
+// KEYS[1]: key
+// ARGV[1]: 版本头标记；ARGV[2]: 写入模式，NX或XX；ARGV[3]: 数据；ARGV[4]: TTL毫秒数，0为不过期
+var modeScript = `
+local exists = redis.call('EXISTS', KEYS[1]) == 1
+if exists and redis.call('STRLEN', KEYS[1]) == 10 and redis.call('GETRANGE', KEYS[1], 0, 1) == ARGV[1] then
+	exists = false
+end
+if (ARGV[2] == 'NX' and exists) or (ARGV[2] == 'XX' and not exists) then
+	return 0
+end
+if ARGV[4] == '0' then
+	redis.call('SET', KEYS[1], ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[3], 'PX', ARGV[4])
+end
+return 1
+`
+
+var modeScriptSHA1 = scriptSHA1(modeScript)
+
+// swapScript 数据仍为读取时的数据时写入
+// KEYS[1]: key
+// ARGV[1]: 读取时key是否存在，1或0；ARGV[2]: 读取时的数据；ARGV[3]: 数据；ARGV[4]: TTL毫秒数，0为不过期
+var swapScript = `
+local current = redis.call('GET', KEYS[1])
+if ARGV[1] == '1' then
+	if current ~= ARGV[2] then
+		return 0
+	end
+elseif current then
+	return 0
+end
+if ARGV[4] == '0' then
+	redis.call('SET', KEYS[1], ARGV[3])
+else
+	redis.call('SET', KEYS[1], ARGV[3], 'PX', ARGV[4])
+end
+return 1
+`
+
+var swapScriptSHA1 = scriptSHA1(swapScript)
+
+func scriptSHA1(script string) string {
+	sum := sha1.Sum([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
+
+// evalScript 执行lua脚本的单个key版本，返回整数结果。脚本未缓存时发送脚本
+func (c *RdsCache) evalScript(ctx context.Context, script, sha string, key string, args ...interface{}) (int, error) {
+	args = append([]interface{}{sha, 1, key}, args...)
+	reply, err := redis.Int(c.client.Do(ctx, "EVALSHA", args...))
+	if err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		args[0] = script
+		reply, err = redis.Int(c.client.Do(ctx, "EVAL", args...))
+	}
+	return reply, err
+}
+
+// wrapVersionToken 在数据前加上随机的版本头。data为空即删除标记
+func wrapVersionToken(data []byte) ([]byte, error) {
+	wrapped := make([]byte, versionTokenHeaderSize, versionTokenHeaderSize+len(data))
+	copy(wrapped, versionTokenMagic[:])
+	for {
+		_, err := rand.Read(wrapped[2:versionTokenHeaderSize])
+		if err != nil {
+			return nil, err
+		}
+		// 0为没有版本头的数据和不存在的key的版本
+		if binary.BigEndian.Uint64(wrapped[2:]) != 0 {
+			break
+		}
+	}
+	return append(wrapped, data...), nil
+}
+
+// hasVersionToken 数据是否以版本头开头
+func hasVersionToken(data []byte) bool {
+	return len(data) >= versionTokenHeaderSize && data[0] == versionTokenMagic[0] && data[1] == versionTokenMagic[1]
+}
+
+// unwrapVersionToken 取出版本和数据。没有版本头的数据，ok返回false，版本为0，原样返回
+func unwrapVersionToken(data []byte) (version uint64, unwrapped []byte, ok bool) {
+	if !hasVersionToken(data) {
+		return 0, data, false
+	}
+	return binary.BigEndian.Uint64(data[2:]), data[versionTokenHeaderSize:], true
+}
+
+// GetWithVersion 获取缓存数据和版本令牌，实现cachex.CASStorage接口。错误语义同Get。
+// 版本见RdsVersionOption，没有版本头的数据和不存在的key，版本为0
+func (c *RdsCache) GetWithVersion(ctx context.Context, key, value interface{}) (uint64, error) {
+	_, version, err := c.get(ctx, key, value)
+	return version, err
+}
+
+// CompareAndSet 仅当key的版本仍为version时设置缓存数据，实现cachex.CASStorage接口。TTL为0时使用默认TTL。返回是否写入。
+// 未配置RdsVersionOption时不比较版本，直接写入，不执行lua脚本
+func (c *RdsCache) CompareAndSet(ctx context.Context, key, value interface{}, version uint64, TTL time.Duration) (bool, error) {
+	if TTL == 0 {
+		TTL = c.defaultTTL
+	}
+	if !c.versioned {
+		err := c.SetWithTTL(ctx, key, value, TTL)
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	skey, err := c.stringKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	data, rdsTTL, err := c.encode(ctx, skey, value, TTL)
+	if err != nil {
+		return false, err
+	}
+
+	expected := make([]byte, 8)
+	binary.BigEndian.PutUint64(expected, version)
+	written, err := c.evalScript(ctx, casScript, casScriptSHA1, skey, versionTokenMagic[:], expected, data, int(rdsTTL/time.Millisecond))
+	if err != nil {
+		return false, err
+	}
+	return written == 1, nil
+}
@@ -0,0 +1,122 @@
+package rdscache
+
+// wencan
+// 2026-10-19
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex"
+)
+
+func TestRdsCacheCompareAndSet(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	// miniredis的lua脚本总是在DB 0执行
+	cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{}, RdsDefaultTTLOption(time.Minute), RdsVersionOption(time.Minute))
+	assert.Implements(t, (*cachex.CASStorage)(nil), cache)
+
+	var value string
+	version, err := cache.GetWithVersion(ctx, "key", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	assert.Equal(t, uint64(0), version)
+
+	written, err := cache.CompareAndSet(ctx, "key", "first", version, 0)
+	assert.NoError(t, err)
+	assert.True(t, written)
+	assert.Equal(t, time.Minute, s.TTL("key"))
+
+	// 版本已变化
+	written, err = cache.CompareAndSet(ctx, "key", "second", version, 0)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	version, err = cache.GetWithVersion(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", value)
+	assert.NotEqual(t, uint64(0), version)
+
+	// 写入后版本变化
+	err = cache.Set(ctx, "key", "second")
+	assert.NoError(t, err)
+	written, err = cache.CompareAndSet(ctx, "key", "third", version, 0)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	version, err = cache.GetWithVersion(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", value)
+	written, err = cache.CompareAndSet(ctx, "key", "third", version, time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, written)
+	assert.Equal(t, time.Hour, s.TTL("key"))
+
+	// 没找到后，查询期间被删除
+	version, err = cache.GetWithVersion(ctx, "other", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	err = cache.Del(ctx, "other")
+	assert.NoError(t, err)
+	err = cache.Get(ctx, "other", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	written, err = cache.CompareAndSet(ctx, "other", "stale", version, 0)
+	assert.NoError(t, err)
+	assert.False(t, written)
+	assert.Equal(t, time.Minute, s.TTL("other"))
+
+	// 没有版本头的数据，版本为0
+	plain := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{})
+	err = plain.Set(ctx, "plain", "plain")
+	assert.NoError(t, err)
+	version, err = cache.GetWithVersion(ctx, "plain", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), version)
+	written, err = cache.CompareAndSet(ctx, "plain", "versioned", version, 0)
+	assert.NoError(t, err)
+	assert.True(t, written)
+	// 未配置版本时，也能读取带版本头的数据
+	err = plain.Get(ctx, "plain", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "versioned", value)
+}
+
+func TestRdsCacheCachexCompareAndSet(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{}, RdsVersionOption(time.Minute))
+
+	var c *cachex.Cachex
+	query := func(ctx context.Context, key, value interface{}) error {
+		// 查询期间数据被更新并失效
+		err := c.Del(ctx, key)
+		assert.NoError(t, err)
+
+		*value.(*string) = "stale"
+		return nil
+	}
+	c = cachex.NewCachex(cache, cachex.QueryFunc(query))
+
+	var value string
+	err = c.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "stale", value)
+
+	// 过时的查询结果没有写入
+	err = cache.Get(ctx, "key", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+}
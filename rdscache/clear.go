@@ -0,0 +1,130 @@
+/*
+ * 按模式删除、清空
+ * 使用SCAN MATCH遍历key，分批UNLINK，批次之间可间隔一段时间，避免长时间阻塞redis
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package rdscache
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/wencan/cachex"
+)
+
+// defaultScanCount 默认的SCAN COUNT
+const defaultScanCount = 100
+
+// DelPattern 删除key匹配glob模式的缓存数据，实现cachex.PatternDeletableStorage接口。
+// 模式匹配的是不含前缀的key（见cachex.KeyString），语法同redis的SCAN MATCH。
+// 每批的数量和批次间隔见RdsScanOption。ctx取消时中止，已删除的数据不会恢复。
+func (c *RdsCache) DelPattern(ctx context.Context, pattern string) error {
+	if c.keyPrefix != "" {
+		pattern = cachex.EscapePattern(c.keyPrefix) + ":" + pattern
+	}
+	return c.delMatch(ctx, pattern)
+}
+
+// Clear 清空缓存的数据，实现cachex.ClearableStorage接口。
+// 删除所有带前缀的key。未配置RdsKeyPrefixOption时无法区分其它应用的key，返回cachex.ErrNotSupported
+func (c *RdsCache) Clear(ctx context.Context) error {
+	if c.keyPrefix == "" {
+		return cachex.ErrNotSupported
+	}
+	return c.DelPattern(ctx, "*")
+}
+
+// delMatch 删除匹配的key。集群模式下遍历所有主节点
+func (c *RdsCache) delMatch(ctx context.Context, pattern string) error {
+	if c.clusterClient == nil {
+		return c.delMatchOn(ctx, c.client, pattern)
+	}
+
+	return c.clusterClient.ForEachMaster(ctx, func(ctx context.Context, node Client) error {
+		return c.delMatchOn(ctx, node, pattern)
+	})
+}
+
+// delMatchOn 删除一个节点上匹配的key。在node上SCAN，通过c.client删除
+func (c *RdsCache) delMatchOn(ctx context.Context, node Client, pattern string) error {
+	count := c.scanCount
+	if count <= 0 {
+		count = defaultScanCount
+	}
+
+	cursor := "0"
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		reply, err := redis.Values(node.Do(ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", count))
+		if err != nil {
+			return err
+		}
+		var keys []interface{}
+		_, err = redis.Scan(reply, &cursor, &keys)
+		if err != nil {
+			return err
+		}
+
+		if len(keys) != 0 {
+			err = c.unlink(ctx, keys)
+			if err != nil {
+				return err
+			}
+		}
+
+		if cursor == "0" {
+			return nil
+		}
+
+		if c.scanInterval > 0 {
+			timer := time.NewTimer(c.scanInterval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+// unlink 删除一批key。集群模式下按slot拆分
+func (c *RdsCache) unlink(ctx context.Context, keys []interface{}) error {
+	if c.clusterClient == nil {
+		return c.unlinkSlot(ctx, keys)
+	}
+
+	for _, group := range groupBySlot(keys) {
+		err := c.unlinkSlot(ctx, group)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unlinkSlot 删除一批key。redis不支持UNLINK（4.0以前）时改用DEL
+func (c *RdsCache) unlinkSlot(ctx context.Context, keys []interface{}) error {
+	if atomic.LoadInt32(&c.noUnlink) == 0 {
+		_, err := c.client.Do(ctx, "UNLINK", keys...)
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(redis.Error); !ok || !strings.Contains(strings.ToLower(err.Error()), "unknown command") {
+			return err
+		}
+		atomic.StoreInt32(&c.noUnlink, 1)
+	}
+
+	_, err := c.client.Do(ctx, "DEL", keys...)
+	return err
+}
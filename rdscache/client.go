@@ -0,0 +1,52 @@
+/*
+ * redis命令执行接口
+ * RdsCache通过Client执行命令，不依赖具体的redis客户端
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package rdscache
+
+import (
+	"context"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Client redis命令执行接口。
+// 回复的类型同redigo：状态回复为string或[]byte，整数为int64，批量字符串为[]byte，数组为[]interface{}，空回复为nil，错误回复为redis.Error。
+type Client interface {
+	// Do 执行命令。操作key的命令，args的第一个参数为key；EVAL、EVALSHA的第一个key为args的第三个参数
+	Do(ctx context.Context, cmd string, args ...interface{}) (reply interface{}, err error)
+}
+
+// ClusterClient redis集群命令执行接口。
+// 集群客户端负责按key路由和处理重定向；RdsCache按slot拆分多key命令，在每个主节点上执行SCAN。
+type ClusterClient interface {
+	Client
+
+	// ForEachMaster 在每个主节点上执行fn，fn可能被并发调用。node为该节点的客户端
+	ForEachMaster(ctx context.Context, fn func(ctx context.Context, node Client) error) error
+}
+
+// redigoClient redigo连接池适配
+type redigoClient struct {
+	pool *redis.Pool
+}
+
+// NewRedigoClient 适配redigo连接池
+func NewRedigoClient(pool *redis.Pool) Client {
+	return redigoClient{pool: pool}
+}
+
+// Do 实现Client接口
+func (c redigoClient) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	conn, err := c.pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return conn.Do(cmd, args...)
+}
@@ -0,0 +1,390 @@
+/*
+ * redigo的redis集群支持
+ * 按key的hash slot路由到所在节点，处理MOVED、ASK重定向
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package rdscache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// clusterSlots redis集群的hash slot数量
+const clusterSlots = 16384
+
+// maxRedirects 单个命令最多跟随重定向的次数
+const maxRedirects = 5
+
+// ErrClusterUnavailable 没有可用的集群节点
+var ErrClusterUnavailable = errors.New("redis cluster unavailable")
+
+// ErrTooManyRedirects 重定向次数过多
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// cluster 基于redigo的redis集群客户端，实现ClusterClient接口。每个节点一个连接池
+type cluster struct {
+	seeds   []string
+	newPool func(address string) *redis.Pool
+
+	lock  sync.RWMutex
+	pools map[string]*redis.Pool
+	// slots 各slot所在主节点的地址，空字符串表示未知
+	slots []string
+}
+
+// NewRedigoClusterClient 创建基于redigo的redis集群客户端。
+// addrs为部分集群节点的地址，用于获取slot分布；内部为每个节点创建连接池，poolCfg作用于每个连接池，集群不支持DB
+func NewRedigoClusterClient(addrs []string, poolCfg PoolConfig) ClusterClient {
+	return newCluster(addrs, func(address string) *redis.Pool {
+		return newPool("tcp", address, poolCfg)
+	})
+}
+
+func newCluster(seeds []string, newPool func(address string) *redis.Pool) *cluster {
+	return &cluster{
+		seeds:   seeds,
+		newPool: newPool,
+		pools:   make(map[string]*redis.Pool),
+		slots:   make([]string, clusterSlots),
+	}
+}
+
+// keySlot 计算key的hash slot。key中包含非空的{...}时，只对第一个{}内的部分计算
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % clusterSlots)
+}
+
+// crc16 CRC16-CCITT（XMODEM），redis集群使用的校验算法
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// pool 返回节点的连接池，没有则创建
+func (c *cluster) pool(address string) *redis.Pool {
+	c.lock.RLock()
+	pool, ok := c.pools[address]
+	c.lock.RUnlock()
+	if ok {
+		return pool
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	pool, ok = c.pools[address]
+	if !ok {
+		pool = c.newPool(address)
+		c.pools[address] = pool
+	}
+	return pool
+}
+
+// nodeOf 返回slot所在节点。slot未知时刷新slot表
+func (c *cluster) nodeOf(ctx context.Context, slot int) (string, error) {
+	c.lock.RLock()
+	address := c.slots[slot]
+	c.lock.RUnlock()
+	if address != "" {
+		return address, nil
+	}
+
+	err := c.refresh(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	c.lock.RLock()
+	address = c.slots[slot]
+	c.lock.RUnlock()
+	if address == "" {
+		return "", ErrClusterUnavailable
+	}
+	return address, nil
+}
+
+// masters 返回所有主节点。slot表为空时先刷新
+func (c *cluster) masters(ctx context.Context) ([]string, error) {
+	collect := func() []string {
+		c.lock.RLock()
+		defer c.lock.RUnlock()
+
+		var addresses []string
+		seen := make(map[string]bool)
+		for _, address := range c.slots {
+			if address != "" && !seen[address] {
+				seen[address] = true
+				addresses = append(addresses, address)
+			}
+		}
+		return addresses
+	}
+
+	addresses := collect()
+	if len(addresses) != 0 {
+		return addresses, nil
+	}
+
+	err := c.refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	addresses = collect()
+	if len(addresses) == 0 {
+		return nil, ErrClusterUnavailable
+	}
+	return addresses, nil
+}
+
+// refresh 通过CLUSTER SLOTS刷新slot表。依次尝试已知节点和种子节点，直到成功
+func (c *cluster) refresh(ctx context.Context) error {
+	c.lock.RLock()
+	candidates := make([]string, 0, len(c.pools)+len(c.seeds))
+	candidates = append(candidates, c.seeds...)
+	for address := range c.pools {
+		candidates = append(candidates, address)
+	}
+	c.lock.RUnlock()
+
+	err := ErrClusterUnavailable
+	for _, address := range candidates {
+		var slots []string
+		slots, err = c.fetchSlots(ctx, address)
+		if err == nil {
+			c.lock.Lock()
+			c.slots = slots
+			c.lock.Unlock()
+			return nil
+		}
+	}
+	return err
+}
+
+// fetchSlots 向节点查询slot表
+func (c *cluster) fetchSlots(ctx context.Context, address string) ([]string, error) {
+	conn, err := c.pool(address).GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ranges, err := redis.Values(conn.Do("CLUSTER", "SLOTS"))
+	if err != nil {
+		return nil, err
+	}
+
+	slots := make([]string, clusterSlots)
+	for _, r := range ranges {
+		// [start, end, [ip, port, ...], 从节点...]
+		fields, err := redis.Values(r, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("unexpected cluster slots reply: %v", fields)
+		}
+		start, err := redis.Int(fields[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		end, err := redis.Int(fields[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		master, err := redis.Values(fields[2], nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(master) < 2 {
+			return nil, fmt.Errorf("unexpected cluster slots reply: %v", fields)
+		}
+		host, err := redis.String(master[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		port, err := redis.Int(master[1], nil)
+		if err != nil {
+			return nil, err
+		}
+		if start < 0 || end >= clusterSlots || start > end {
+			return nil, fmt.Errorf("unexpected cluster slots range: %d-%d", start, end)
+		}
+
+		node := host + ":" + strconv.Itoa(port)
+		for slot := start; slot <= end; slot++ {
+			slots[slot] = node
+		}
+	}
+	return slots, nil
+}
+
+// parseRedirect 解析MOVED、ASK重定向错误
+func parseRedirect(err error) (ask bool, slot int, address string, ok bool) {
+	rdsErr, isRdsErr := err.(redis.Error)
+	if !isRdsErr {
+		return false, 0, "", false
+	}
+	fields := strings.Fields(string(rdsErr))
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return false, 0, "", false
+	}
+	slot, convErr := strconv.Atoi(fields[1])
+	if convErr != nil || slot < 0 || slot >= clusterSlots {
+		return false, 0, "", false
+	}
+	return fields[0] == "ASK", slot, fields[2], true
+}
+
+// retryable 判断命令失败后是否应刷新slot表重试：连接、读写错误，以及CLUSTERDOWN、TRYAGAIN。ctx已结束时不重试
+func retryable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	rdsErr, isRdsErr := err.(redis.Error)
+	if !isRdsErr {
+		return err != redis.ErrPoolExhausted
+	}
+	return strings.HasPrefix(string(rdsErr), "CLUSTERDOWN") || strings.HasPrefix(string(rdsErr), "TRYAGAIN")
+}
+
+// Do 实现Client接口。按第一个参数（key）路由，EVAL、EVALSHA按第一个key路由，跟随MOVED、ASK重定向；没有参数的命令在任一主节点执行。
+// 节点连接失败或集群返回CLUSTERDOWN、TRYAGAIN时，刷新slot表后重试一次
+func (c *cluster) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	reply, err := c.do(ctx, cmd, args...)
+	if retryable(ctx, err) {
+		// 节点可能已下线或发生了主从切换
+		refreshErr := c.refresh(ctx)
+		if refreshErr == nil {
+			reply, err = c.do(ctx, cmd, args...)
+		}
+	}
+	return reply, err
+}
+
+// do 路由并执行一次命令，跟随重定向
+func (c *cluster) do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	var address string
+	var err error
+	if len(args) == 0 {
+		var masters []string
+		masters, err = c.masters(ctx)
+		if err == nil {
+			address = masters[0]
+		}
+	} else {
+		keyArg := args[0]
+		if (strings.EqualFold(cmd, "EVAL") || strings.EqualFold(cmd, "EVALSHA")) && len(args) >= 3 {
+			// EVAL script numkeys key ...
+			keyArg = args[2]
+		}
+		address, err = c.nodeOf(ctx, keySlot(argString(keyArg)))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	asking := false
+	for i := 0; i <= maxRedirects; i++ {
+		reply, err := c.doOn(ctx, address, asking, cmd, args...)
+		ask, redirectSlot, redirectAddress, redirected := parseRedirect(err)
+		if !redirected {
+			return reply, err
+		}
+
+		if !ask {
+			// slot已迁移，更新slot表
+			c.lock.Lock()
+			c.slots[redirectSlot] = redirectAddress
+			c.lock.Unlock()
+		}
+		address, asking = redirectAddress, ask
+	}
+	return nil, ErrTooManyRedirects
+}
+
+// ForEachMaster 实现ClusterClient接口，依次在每个主节点上执行fn
+func (c *cluster) ForEachMaster(ctx context.Context, fn func(ctx context.Context, node Client) error) error {
+	masters, err := c.masters(ctx)
+	if err != nil {
+		return err
+	}
+	for _, address := range masters {
+		err = fn(ctx, redigoClient{pool: c.pool(address)})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// doOn 在指定节点上执行命令。asking为真时先发送ASKING
+func (c *cluster) doOn(ctx context.Context, address string, asking bool, cmd string, args ...interface{}) (interface{}, error) {
+	conn, err := c.pool(address).GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if asking {
+		_, err = conn.Do("ASKING")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return conn.Do(cmd, args...)
+}
+
+// argString 将命令参数转为字符串
+func argString(arg interface{}) string {
+	switch arg := arg.(type) {
+	case string:
+		return arg
+	case []byte:
+		return string(arg)
+	default:
+		return fmt.Sprint(arg)
+	}
+}
+
+// groupBySlot 按slot分组key，保持各组内key的顺序
+func groupBySlot(keys []interface{}) [][]interface{} {
+	groups := make(map[int][]interface{})
+	var order []int
+	for _, key := range keys {
+		slot := keySlot(argString(key))
+		if _, exists := groups[slot]; !exists {
+			order = append(order, slot)
+		}
+		groups[slot] = append(groups[slot], key)
+	}
+
+	grouped := make([][]interface{}, 0, len(order))
+	for _, slot := range order {
+		grouped = append(grouped, groups[slot])
+	}
+	return grouped
+}
@@ -0,0 +1,353 @@
+package rdscache
+
+// wencan
+// 2026-10-19
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex"
+)
+
+// fakeCluster 模拟redis集群，只支持测试用到的命令。数据所有节点共享，slot归属只影响路由
+type fakeCluster struct {
+	lock  sync.Mutex
+	data  map[string]string
+	nodes []*server.Server
+	// owners 各slot的归属节点
+	owners []int
+	// migrating 正在迁移的slot到目标节点，源节点返回ASK
+	migrating map[int]int
+	// asked 各节点收到的ASKING次数
+	asked []int
+	// down 接下来操作key的命令中，返回CLUSTERDOWN的次数
+	down int
+}
+
+func newFakeCluster(t *testing.T, nodes int) *fakeCluster {
+	c := &fakeCluster{
+		data:      make(map[string]string),
+		owners:    make([]int, clusterSlots),
+		migrating: make(map[int]int),
+		asked:     make([]int, nodes),
+	}
+	for slot := range c.owners {
+		c.owners[slot] = slot * nodes / clusterSlots
+	}
+
+	for idx := 0; idx < nodes; idx++ {
+		s, err := server.NewServer("127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.nodes = append(c.nodes, s)
+
+		node := idx
+		s.Register("CLUSTER", c.cmdClusterSlots)
+		s.Register("ASKING", func(peer *server.Peer, cmd string, args []string) {
+			c.lock.Lock()
+			c.asked[node]++
+			c.lock.Unlock()
+			peer.Ctx = true
+			peer.WriteOK()
+		})
+		s.Register("GET", c.keysCmd(node, 0, 0, func(peer *server.Peer, args []string) {
+			value, ok := c.data[args[0]]
+			if !ok {
+				peer.WriteNull()
+				return
+			}
+			peer.WriteBulk(value)
+		}))
+		s.Register("SET", c.keysCmd(node, 0, 0, func(peer *server.Peer, args []string) {
+			c.data[args[0]] = args[1]
+			peer.WriteOK()
+		}))
+		s.Register("DEL", c.keysCmd(node, 0, -1, func(peer *server.Peer, args []string) {
+			var deleted int
+			for _, key := range args {
+				if _, ok := c.data[key]; ok {
+					delete(c.data, key)
+					deleted++
+				}
+			}
+			peer.WriteInt(deleted)
+		}))
+		s.Register("SCAN", func(peer *server.Peer, cmd string, args []string) {
+			c.lock.Lock()
+			defer c.lock.Unlock()
+
+			// 一次返回本节点所有匹配的key
+			var keys []string
+			for key := range c.data {
+				if c.owners[keySlot(key)] == node && cachex.MatchPattern(args[2], key) {
+					keys = append(keys, key)
+				}
+			}
+			peer.WriteLen(2)
+			peer.WriteBulk("0")
+			peer.WriteLen(len(keys))
+			for _, key := range keys {
+				peer.WriteBulk(key)
+			}
+		})
+	}
+	return c
+}
+
+// cmdClusterSlots 实现CLUSTER SLOTS
+func (c *fakeCluster) cmdClusterSlots(peer *server.Peer, cmd string, args []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	type slotRange struct{ start, end, node int }
+	var ranges []slotRange
+	for slot, node := range c.owners {
+		if len(ranges) != 0 && ranges[len(ranges)-1].node == node {
+			ranges[len(ranges)-1].end = slot
+			continue
+		}
+		ranges = append(ranges, slotRange{slot, slot, node})
+	}
+
+	peer.WriteLen(len(ranges))
+	for _, r := range ranges {
+		addr := c.nodes[r.node].Addr()
+		peer.WriteLen(3)
+		peer.WriteInt(r.start)
+		peer.WriteInt(r.end)
+		peer.WriteLen(2)
+		peer.WriteBulk(addr.IP.String())
+		peer.WriteInt(addr.Port)
+	}
+}
+
+// keysCmd 包装操作key的命令，检查key的slot是否属于节点。keys为[first, last]的key参数，last为-1表示到最后
+func (c *fakeCluster) keysCmd(node, first, last int, f func(peer *server.Peer, args []string)) server.Cmd {
+	return func(peer *server.Peer, cmd string, args []string) {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		asking := peer.Ctx == true
+		peer.Ctx = nil
+
+		if c.down > 0 {
+			c.down--
+			peer.WriteError("CLUSTERDOWN The cluster is down")
+			return
+		}
+
+		if last < 0 {
+			last = len(args) - 1
+		}
+		slot := keySlot(args[first])
+		for _, key := range args[first : last+1] {
+			if keySlot(key) != slot {
+				peer.WriteError("CROSSSLOT Keys in request don't hash to the same slot")
+				return
+			}
+		}
+
+		if target, ok := c.migrating[slot]; ok {
+			if target == node && asking {
+				f(peer, args)
+				return
+			}
+			if c.owners[slot] == node {
+				peer.WriteError(fmt.Sprintf("ASK %d %s", slot, c.nodes[target].Addr()))
+				return
+			}
+		}
+		if owner := c.owners[slot]; owner != node {
+			peer.WriteError(fmt.Sprintf("MOVED %d %s", slot, c.nodes[owner].Addr()))
+			return
+		}
+		f(peer, args)
+	}
+}
+
+func (c *fakeCluster) addrs() []string {
+	var addrs []string
+	for _, s := range c.nodes {
+		addrs = append(addrs, s.Addr().String())
+	}
+	return addrs
+}
+
+func (c *fakeCluster) Close() {
+	for _, s := range c.nodes {
+		s.Close()
+	}
+}
+
+func TestKeySlot(t *testing.T) {
+	assert.Equal(t, 12739, keySlot("123456789"))
+	assert.Equal(t, keySlot("user1000"), keySlot("{user1000}.following"))
+	assert.Equal(t, keySlot("{user1000}.following"), keySlot("{user1000}.followers"))
+	assert.Equal(t, keySlot("bar"), keySlot("foo{bar}{zap}"))
+	assert.Equal(t, keySlot("{bar"), keySlot("foo{{bar}}zap"))
+	assert.Equal(t, int(crc16("foo{}{bar}")%clusterSlots), keySlot("foo{}{bar}"))
+}
+
+func TestRdsClusterCache(t *testing.T) {
+	fake := newFakeCluster(t, 3)
+	defer fake.Close()
+
+	ctx := context.Background()
+
+	// 只给一个种子节点
+	cache := NewRdsClusterCache(ctx, fake.addrs()[:1], PoolConfig{}, RdsKeyPrefixOption("prefix"))
+	assert.Implements(t, (*cachex.DeletableStorage)(nil), cache)
+
+	var keys []interface{}
+	nodes := make(map[int]bool)
+	for i := 0; i < 20; i++ {
+		key := "key" + strconv.Itoa(i)
+		keys = append(keys, key)
+		nodes[fake.owners[keySlot("prefix:"+key)]] = true
+
+		err := cache.Set(ctx, key, i)
+		assert.NoError(t, err)
+	}
+	// 分布到了所有节点
+	assert.Len(t, nodes, 3)
+
+	for i, key := range keys {
+		var value int
+		err := cache.Get(ctx, key, &value)
+		if assert.NoError(t, err) {
+			assert.Equal(t, i, value)
+		}
+	}
+
+	// MOVED
+	slot := keySlot("prefix:key0")
+	fake.lock.Lock()
+	fake.owners[slot] = (fake.owners[slot] + 1) % 3
+	fake.lock.Unlock()
+	var value int
+	err := cache.Get(ctx, "key0", &value)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, value)
+	}
+	assert.Equal(t, fake.nodes[fake.owners[slot]].Addr().String(), cache.client.(*cluster).slots[slot])
+
+	// ASK
+	slot = keySlot("prefix:key1")
+	target := (fake.owners[slot] + 1) % 3
+	fake.lock.Lock()
+	fake.migrating[slot] = target
+	fake.lock.Unlock()
+	err = cache.Get(ctx, "key1", &value)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, value)
+	}
+	assert.Equal(t, 1, fake.asked[target])
+	fake.lock.Lock()
+	delete(fake.migrating, slot)
+	fake.lock.Unlock()
+
+	// 多key按slot拆分
+	err = cache.Del(ctx, "key2", "key3", "key4")
+	assert.NoError(t, err)
+	assert.Len(t, fake.data, 17)
+
+	// 清空所有节点
+	err = cache.Clear(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, fake.data, 0)
+}
+
+func TestRdsClusterCacheFailover(t *testing.T) {
+	fake := newFakeCluster(t, 3)
+	defer fake.Close()
+
+	ctx := context.Background()
+
+	cache := NewRdsClusterCache(ctx, fake.addrs(), PoolConfig{}, RdsKeyPrefixOption("prefix"))
+
+	var keys []string
+	for i := 0; i < 20; i++ {
+		key := "key" + strconv.Itoa(i)
+		keys = append(keys, key)
+
+		err := cache.Set(ctx, key, i)
+		assert.NoError(t, err)
+	}
+
+	// CLUSTERDOWN后刷新slot表并重试
+	fake.lock.Lock()
+	fake.down = 1
+	fake.lock.Unlock()
+	var value int
+	err := cache.Get(ctx, "key0", &value)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, value)
+	}
+
+	// 节点0下线，slot由节点1接管
+	deadAddr := fake.nodes[0].Addr().String()
+	fake.nodes[0].Close()
+	fake.lock.Lock()
+	for slot, node := range fake.owners {
+		if node == 0 {
+			fake.owners[slot] = 1
+		}
+	}
+	fake.lock.Unlock()
+
+	for i, key := range keys {
+		var value int
+		err := cache.Get(ctx, key, &value)
+		if assert.NoError(t, err) {
+			assert.Equal(t, i, value)
+		}
+	}
+	for _, address := range cache.client.(*cluster).slots {
+		assert.NotEqual(t, deadAddr, address)
+	}
+}
+
+func TestRdsCacheHashTag(t *testing.T) {
+	fake := newFakeCluster(t, 3)
+	defer fake.Close()
+
+	ctx := context.Background()
+
+	hashTag := func(key string) string {
+		// user:1:profile、user:1:orders位于同一个slot
+		fields := strings.SplitN(key, ":", 3)
+		if len(fields) < 2 {
+			return ""
+		}
+		return fields[0] + ":" + fields[1]
+	}
+	cache := NewRdsClusterCache(ctx, fake.addrs(), PoolConfig{}, RdsKeyPrefixOption("prefix"), RdsHashTagOption(hashTag))
+
+	err := cache.Set(ctx, "user:1:profile", "profile")
+	assert.NoError(t, err)
+	err = cache.Set(ctx, "user:1:orders", "orders")
+	assert.NoError(t, err)
+	err = cache.Set(ctx, "other", "other")
+	assert.NoError(t, err)
+	assert.Contains(t, fake.data, "prefix:{user:1}user:1:profile")
+	assert.Contains(t, fake.data, "prefix:{user:1}user:1:orders")
+	assert.Contains(t, fake.data, "prefix:other")
+
+	var value string
+	err = cache.Get(ctx, "user:1:orders", &value)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "orders", value)
+	}
+
+	err = cache.DelPattern(ctx, "{user:1}*")
+	assert.NoError(t, err)
+	assert.Len(t, fake.data, 1)
+}
@@ -0,0 +1,136 @@
+/*
+ * 数据编解码
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package rdscache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"reflect"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/wencan/cachex"
+)
+
+// ErrUnsupportedValue 编解码器不支持的值类型
+var ErrUnsupportedValue = errors.New("value type is unacceptable")
+
+// Codec 数据编解码接口，同cachex.Codec
+type Codec = cachex.Codec
+
+// varsCodec 使用包变量Marshal、Unmarshal的编解码器。未配置编解码器时使用，保持兼容
+type varsCodec struct{}
+
+func (varsCodec) Marshal(v interface{}) ([]byte, error) {
+	return Marshal(v)
+}
+
+func (varsCodec) Unmarshal(data []byte, v interface{}) error {
+	return Unmarshal(data, v)
+}
+
+// MsgpackCodec msgpack编解码器，同cachex.MsgpackCodec
+type MsgpackCodec = cachex.MsgpackCodec
+
+// JSONCodec json编解码器，同cachex.JSONCodec
+type JSONCodec = cachex.JSONCodec
+
+// GobCodec gob编解码器。每个值独立编码，包含类型信息
+type GobCodec struct{}
+
+// Marshal 实现Codec接口
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal 实现Codec接口
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// ProtobufCodec protobuf编解码器。值或值的指针必须实现proto.Message，否则返回ErrUnsupportedValue
+type ProtobufCodec struct{}
+
+// Marshal 实现Codec接口。cachex回写的是非指针的值，取其指针判断是否实现proto.Message
+func (ProtobufCodec) Marshal(v interface{}) ([]byte, error) {
+	message, ok := v.(proto.Message)
+	if !ok {
+		message, ok = pointerTo(v).(proto.Message)
+	}
+	if !ok {
+		return nil, ErrUnsupportedValue
+	}
+	return proto.Marshal(message)
+}
+
+// Unmarshal 实现Codec接口
+func (ProtobufCodec) Unmarshal(data []byte, v interface{}) error {
+	message, ok := v.(proto.Message)
+	if !ok {
+		return ErrUnsupportedValue
+	}
+	return proto.Unmarshal(data, message)
+}
+
+// pointerTo 返回指向v的副本的指针，用于判断指针接收者的方法。v为nil或已是指针时原样返回
+func pointerTo(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || rv.Kind() == reflect.Ptr {
+		return v
+	}
+	ptr := reflect.New(rv.Type())
+	ptr.Elem().Set(rv)
+	return ptr.Interface()
+}
+
+// RawCodec 直通编解码器。[]byte和string不编码，原样保存；
+// 其它类型的值交给Fallback编解码，Fallback为nil时返回ErrUnsupportedValue
+type RawCodec struct {
+	Fallback Codec
+}
+
+// Marshal 实现Codec接口
+func (c RawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case []byte:
+		return t, nil
+	case *[]byte:
+		return *t, nil
+	case string:
+		return []byte(t), nil
+	case *string:
+		return []byte(*t), nil
+	}
+
+	if c.Fallback == nil {
+		return nil, ErrUnsupportedValue
+	}
+	return c.Fallback.Marshal(v)
+}
+
+// Unmarshal 实现Codec接口
+func (c RawCodec) Unmarshal(data []byte, v interface{}) error {
+	switch t := v.(type) {
+	case *[]byte:
+		*t = append((*t)[:0], data...)
+		return nil
+	case *string:
+		*t = string(data)
+		return nil
+	}
+
+	if c.Fallback == nil {
+		return ErrUnsupportedValue
+	}
+	return c.Fallback.Unmarshal(data, v)
+}
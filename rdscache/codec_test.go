@@ -0,0 +1,129 @@
+package rdscache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex"
+)
+
+type testCodecValue struct {
+	Name  string
+	Count int
+}
+
+func TestRdsCacheCodec(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	codecs := []Codec{MsgpackCodec{}, JSONCodec{}, GobCodec{}, RawCodec{Fallback: MsgpackCodec{}}}
+	for _, codec := range codecs {
+		cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1}, RdsCodecOption(codec))
+
+		value := testCodecValue{Name: "test", Count: 10}
+		err = cache.Set(ctx, "struct", value)
+		if assert.NoError(t, err) {
+			var cached testCodecValue
+			err = cache.Get(ctx, "struct", &cached)
+			assert.NoError(t, err)
+			assert.Equal(t, value, cached)
+		}
+	}
+
+	// 不同编解码器的实例共存
+	jsonCache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1}, RdsCodecOption(JSONCodec{}), RdsKeyPrefixOption("json"))
+	err = jsonCache.Set(ctx, "key", map[string]int{"a": 1})
+	if assert.NoError(t, err) {
+		data, _ := s.DB(1).Get("json:key")
+		assert.Equal(t, `{"a":1}`, data)
+	}
+}
+
+func TestRdsCacheRawCodec(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1}, RdsCodecOption(RawCodec{}))
+
+	// 不编码，原样保存
+	err = cache.Set(ctx, "string", "raw string")
+	if assert.NoError(t, err) {
+		data, _ := s.DB(1).Get("string")
+		assert.Equal(t, "raw string", data)
+
+		var cached []byte
+		err = cache.Get(ctx, "string", &cached)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("raw string"), cached)
+	}
+
+	// 以版本头开头的原始字节，不误判为版本头或删除标记
+	for _, raw := range [][]byte{
+		append(versionTokenMagic[:], 1, 2, 3, 4, 5, 6, 7, 8),
+		append(versionTokenMagic[:], 1, 2, 3, 4, 5, 6, 7, 8, 'a'),
+	} {
+		err = cache.Set(ctx, "magic", raw)
+		if assert.NoError(t, err) {
+			var cached []byte
+			err = cache.Get(ctx, "magic", &cached)
+			assert.NoError(t, err)
+			assert.Equal(t, raw, cached)
+		}
+	}
+
+	err = cache.Set(ctx, "int", 10)
+	assert.Equal(t, ErrUnsupportedValue, err)
+}
+
+func TestRdsCacheProtobufCodec(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1}, RdsCodecOption(ProtobufCodec{}))
+
+	err = cache.Set(ctx, "message", &wrappers.StringValue{Value: "test"})
+	if assert.NoError(t, err) {
+		var cached wrappers.StringValue
+		err = cache.Get(ctx, "message", &cached)
+		assert.NoError(t, err)
+		assert.True(t, proto.Equal(&wrappers.StringValue{Value: "test"}, &cached))
+	}
+
+	err = cache.Set(ctx, "int", 10)
+	assert.Equal(t, ErrUnsupportedValue, err)
+
+	// cachex回写非指针的值
+	query := func(ctx context.Context, key, value interface{}) error {
+		value.(*wrappers.StringValue).Value = "queried"
+		return nil
+	}
+	c := cachex.NewCachex(cache, cachex.QueryFunc(query))
+	var value wrappers.StringValue
+	err = c.Get(ctx, "queried", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "queried", value.Value)
+
+	var cached wrappers.StringValue
+	err = cache.Get(ctx, "queried", &cached)
+	assert.NoError(t, err)
+	assert.Equal(t, "queried", cached.Value)
+}
@@ -0,0 +1,130 @@
+/*
+ * 数据压缩
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package rdscache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression 压缩算法
+type Compression byte
+
+const (
+	// NoCompression 不压缩
+	NoCompression Compression = iota
+
+	// GzipCompression gzip压缩
+	GzipCompression
+
+	// SnappyCompression snappy压缩
+	SnappyCompression
+
+	// ZstdCompression zstd压缩
+	ZstdCompression
+)
+
+// compressMagic 压缩头的标记字节。msgpack不使用该字节
+const compressMagic byte = 0xC1
+
+// ErrUnknownCompression 未知的压缩算法
+var ErrUnknownCompression = errors.New("unknown compression")
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// initZstd 初始化共享的zstd编解码器。EncodeAll、DecodeAll并发安全
+func initZstd() error {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil)
+		if zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+	return zstdErr
+}
+
+// compress 压缩。数据长度不小于threshold时按compression压缩，加上标记字节和算法字节的头。
+// 不压缩的数据原样返回；如果以标记字节开头，加上不压缩的头，避免读取时误判
+func compress(compression Compression, threshold int, data []byte) ([]byte, error) {
+	if compression == NoCompression || len(data) < threshold {
+		if len(data) > 0 && data[0] == compressMagic {
+			return append([]byte{compressMagic, byte(NoCompression)}, data...), nil
+		}
+		return data, nil
+	}
+
+	var compressed []byte
+	switch compression {
+	case GzipCompression:
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		_, err := writer.Write(data)
+		if err != nil {
+			return nil, err
+		}
+		err = writer.Close()
+		if err != nil {
+			return nil, err
+		}
+		compressed = buf.Bytes()
+	case SnappyCompression:
+		compressed = snappy.Encode(nil, data)
+	case ZstdCompression:
+		err := initZstd()
+		if err != nil {
+			return nil, err
+		}
+		compressed = zstdEncoder.EncodeAll(data, nil)
+	default:
+		return nil, ErrUnknownCompression
+	}
+
+	return append([]byte{compressMagic, byte(compression)}, compressed...), nil
+}
+
+// decompress 解压。根据头选择算法，没有头的数据原样返回
+func decompress(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != compressMagic {
+		return data, nil
+	}
+
+	compressed := data[2:]
+	switch Compression(data[1]) {
+	case NoCompression:
+		return compressed, nil
+	case GzipCompression:
+		reader, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	case SnappyCompression:
+		return snappy.Decode(nil, compressed)
+	case ZstdCompression:
+		err := initZstd()
+		if err != nil {
+			return nil, err
+		}
+		return zstdDecoder.DecodeAll(compressed, nil)
+	default:
+		return nil, ErrUnknownCompression
+	}
+}
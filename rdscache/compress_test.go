@@ -0,0 +1,81 @@
+package rdscache
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRdsCacheCompress(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	large := strings.Repeat("compressible ", 1000)
+	small := "small"
+
+	compressions := []Compression{GzipCompression, SnappyCompression, ZstdCompression}
+	for _, compression := range compressions {
+		s.DB(1).FlushDB()
+		cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1}, RdsCompressOption(compression, 1024))
+
+		err = cache.Set(ctx, "large", large)
+		if assert.NoError(t, err) {
+			// 压缩的数据带有头
+			data, _ := s.DB(1).Get("large")
+			assert.True(t, len(data) < len(large))
+			assert.Equal(t, compressMagic, data[0])
+			assert.Equal(t, byte(compression), data[1])
+
+			var cached string
+			err = cache.Get(ctx, "large", &cached)
+			assert.NoError(t, err)
+			assert.Equal(t, large, cached)
+		}
+
+		// 小于阈值的数据不压缩
+		err = cache.Set(ctx, "small", small)
+		if assert.NoError(t, err) {
+			var cached string
+			err = cache.Get(ctx, "small", &cached)
+			assert.NoError(t, err)
+			assert.Equal(t, small, cached)
+		}
+
+		// 更换算法后，仍能读取
+		another := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1}, RdsCompressOption(NoCompression, 0))
+		var cached string
+		err = another.Get(ctx, "large", &cached)
+		assert.NoError(t, err)
+		assert.Equal(t, large, cached)
+	}
+}
+
+func TestRdsCacheCompressMagicValue(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1}, RdsCodecOption(RawCodec{}), RdsCompressOption(SnappyCompression, 1024))
+
+	// 不压缩但以标记字节开头的数据，读取时不误判
+	value := []byte{compressMagic, byte(GzipCompression), 1, 2, 3}
+	err = cache.Set(ctx, "magic", value)
+	if assert.NoError(t, err) {
+		var cached []byte
+		err = cache.Get(ctx, "magic", &cached)
+		assert.NoError(t, err)
+		assert.Equal(t, value, cached)
+	}
+}
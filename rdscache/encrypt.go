@@ -0,0 +1,130 @@
+/*
+ * 数据加密
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package rdscache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// encryptVersion 加密数据格式版本
+const encryptVersion byte = 1
+
+var (
+	// ErrKeyNotFound 密钥不存在
+	ErrKeyNotFound = errors.New("encryption key not found")
+
+	// ErrMalformedCiphertext 密文格式错误
+	ErrMalformedCiphertext = errors.New("malformed ciphertext")
+)
+
+// KeyProvider 加密密钥提供接口。密钥长度为16、24或32字节，分别对应AES-128、AES-192、AES-256
+type KeyProvider interface {
+	// CurrentKey 返回当前用于加密的密钥和密钥ID。密钥ID不超过255字节
+	CurrentKey() (keyID string, key []byte, err error)
+
+	// Key 返回指定ID的密钥，用于解密。轮换后，旧密钥需要保留到旧数据过期
+	Key(keyID string) ([]byte, error)
+}
+
+// StaticKeyProvider 固定的密钥集合，实现KeyProvider接口
+type StaticKeyProvider struct {
+	// CurrentKeyID 当前用于加密的密钥ID
+	CurrentKeyID string
+
+	// Keys 密钥ID到密钥的映射
+	Keys map[string][]byte
+}
+
+// CurrentKey 实现KeyProvider接口
+func (p StaticKeyProvider) CurrentKey() (string, []byte, error) {
+	key, err := p.Key(p.CurrentKeyID)
+	if err != nil {
+		return "", nil, err
+	}
+	return p.CurrentKeyID, key, nil
+}
+
+// Key 实现KeyProvider接口
+func (p StaticKeyProvider) Key(keyID string) ([]byte, error) {
+	key, ok := p.Keys[keyID]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+// newGCM 创建AES-GCM
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypt 使用当前密钥加密。additional为附加认证数据，解密时需一致。
+// 格式：版本(1) + 密钥ID长度(1) + 密钥ID + nonce + 密文
+func encrypt(provider KeyProvider, data, additional []byte) ([]byte, error) {
+	keyID, key, err := provider.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(keyID) > 255 {
+		return nil, errors.New("key id too long")
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, 2+len(keyID)+gcm.NonceSize())
+	header = append(header, encryptVersion, byte(len(keyID)))
+	header = append(header, keyID...)
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+	header = append(header, nonce...)
+
+	return gcm.Seal(header, nonce, data, additional), nil
+}
+
+// decrypt 按数据中的密钥ID选择密钥解密
+func decrypt(provider KeyProvider, data, additional []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != encryptVersion {
+		return nil, ErrMalformedCiphertext
+	}
+	idLen := int(data[1])
+	if len(data) < 2+idLen {
+		return nil, ErrMalformedCiphertext
+	}
+	keyID := string(data[2 : 2+idLen])
+	data = data[2+idLen:]
+
+	key, err := provider.Key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrMalformedCiphertext
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, additional)
+}
@@ -0,0 +1,80 @@
+package rdscache
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis"
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex"
+)
+
+func TestRdsCacheEncrypt(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 16)
+
+	provider := StaticKeyProvider{
+		CurrentKeyID: "old",
+		Keys:         map[string][]byte{"old": oldKey},
+	}
+	cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1}, RdsEncryptOption(provider), RdsCompressOption(GzipCompression, 16))
+
+	value := "personal identifiable information"
+	err = cache.Set(ctx, "user", value)
+	if assert.NoError(t, err) {
+		// 保存的是密文
+		data, _ := s.DB(1).Get("user")
+		assert.NotContains(t, data, value)
+
+		var cached string
+		err = cache.Get(ctx, "user", &cached)
+		assert.NoError(t, err)
+		assert.Equal(t, value, cached)
+	}
+
+	// 轮换密钥后，旧数据仍可读取，新数据使用新密钥
+	rotated := StaticKeyProvider{
+		CurrentKeyID: "new",
+		Keys:         map[string][]byte{"old": oldKey, "new": newKey},
+	}
+	cache = NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1}, RdsEncryptOption(rotated), RdsCompressOption(GzipCompression, 16))
+
+	var cached string
+	err = cache.Get(ctx, "user", &cached)
+	assert.NoError(t, err)
+	assert.Equal(t, value, cached)
+
+	err = cache.Set(ctx, "new", value)
+	if assert.NoError(t, err) {
+		err = cache.Get(ctx, "new", &cached)
+		assert.NoError(t, err)
+		assert.Equal(t, value, cached)
+	}
+
+	// 密钥已删除、数据被挪到其它key、未加密的数据，均视为没找到
+	removed := StaticKeyProvider{
+		CurrentKeyID: "new",
+		Keys:         map[string][]byte{"new": newKey},
+	}
+	cache = NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1}, RdsEncryptOption(removed), RdsCompressOption(GzipCompression, 16))
+	err = cache.Get(ctx, "user", &cached)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+
+	data, _ := s.DB(1).Get("new")
+	s.DB(1).Set("moved", data)
+	err = cache.Get(ctx, "moved", &cached)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+
+	s.DB(1).Set("plain", "plain")
+	err = cache.Get(ctx, "plain", &cached)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+}
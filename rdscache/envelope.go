@@ -0,0 +1,71 @@
+/*
+ * 带逻辑过期时间的数据信封
+ * redis在TTL到期后删除key，信封记录逻辑过期时间，使逻辑过期后、redis删除前可以读到过期数据
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package rdscache
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// envelopeMagic 信封头。第一个字节与压缩头的标记字节相同，msgpack不使用该字节
+var envelopeMagic = [2]byte{compressMagic, 0xE0}
+
+// envelopeHeaderSize 信封头长度：标记(2) + 写入时间(8) + 逻辑过期时间(8) + 物理过期时间(8)
+const envelopeHeaderSize = 2 + 8*3
+
+// ErrMalformedEnvelope 信封格式错误
+var ErrMalformedEnvelope = errors.New("malformed envelope")
+
+// envelope 信封头。时间均为UnixNano，过期时间为0表示不过期
+type envelope struct {
+	// writeTime 写入时间
+	writeTime int64
+
+	// softExpire 逻辑过期时间，之后读取返回过期数据
+	softExpire int64
+
+	// hardExpire 物理过期时间，即redis删除key的时间
+	hardExpire int64
+}
+
+// wrapEnvelope 将编码后的数据装入信封
+func wrapEnvelope(env envelope, data []byte) []byte {
+	wrapped := make([]byte, envelopeHeaderSize, envelopeHeaderSize+len(data))
+	copy(wrapped, envelopeMagic[:])
+	binary.BigEndian.PutUint64(wrapped[2:], uint64(env.writeTime))
+	binary.BigEndian.PutUint64(wrapped[10:], uint64(env.softExpire))
+	binary.BigEndian.PutUint64(wrapped[18:], uint64(env.hardExpire))
+	return append(wrapped, data...)
+}
+
+// unwrapEnvelope 从信封中取出编码后的数据。没有信封的数据，ok返回false，原样返回
+func unwrapEnvelope(data []byte) (env envelope, unwrapped []byte, ok bool, err error) {
+	if len(data) < 2 || data[0] != envelopeMagic[0] || data[1] != envelopeMagic[1] {
+		return envelope{}, data, false, nil
+	}
+	if len(data) < envelopeHeaderSize {
+		return envelope{}, nil, false, ErrMalformedEnvelope
+	}
+
+	env.writeTime = int64(binary.BigEndian.Uint64(data[2:]))
+	env.softExpire = int64(binary.BigEndian.Uint64(data[10:]))
+	env.hardExpire = int64(binary.BigEndian.Uint64(data[18:]))
+	return env, data[envelopeHeaderSize:], true, nil
+}
+
+// expired 是否已逻辑过期
+func (env envelope) expired(now time.Time) bool {
+	return env.softExpire != 0 && now.UnixNano() >= env.softExpire
+}
+
+// age 数据的年龄
+func (env envelope) age(now time.Time) time.Duration {
+	return time.Duration(now.UnixNano() - env.writeTime)
+}
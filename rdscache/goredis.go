@@ -0,0 +1,80 @@
+/*
+ * go-redis客户端适配
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package rdscache
+
+import (
+	"context"
+
+	"github.com/gomodule/redigo/redis"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// goRedisClient go-redis客户端适配
+type goRedisClient struct {
+	client goredis.UniversalClient
+}
+
+// goRedisClusterClient go-redis集群客户端适配
+type goRedisClusterClient struct {
+	goRedisClient
+	cluster *goredis.ClusterClient
+}
+
+// NewGoRedisClient 适配go-redis客户端，可复用现有客户端的连接配置和hook。
+// client为*redis.ClusterClient时返回ClusterClient
+func NewGoRedisClient(client goredis.UniversalClient) Client {
+	if cluster, ok := client.(*goredis.ClusterClient); ok {
+		return goRedisClusterClient{
+			goRedisClient: goRedisClient{client: client},
+			cluster:       cluster,
+		}
+	}
+	return goRedisClient{client: client}
+}
+
+// Do 实现Client接口。回复转为redigo的类型
+func (c goRedisClient) Do(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	cmdArgs := make([]interface{}, 0, 1+len(args))
+	cmdArgs = append(cmdArgs, cmd)
+	cmdArgs = append(cmdArgs, args...)
+
+	reply, err := c.client.Do(ctx, cmdArgs...).Result()
+	if err == goredis.Nil {
+		return nil, nil
+	}
+	if rdsErr, ok := err.(goredis.Error); ok {
+		return nil, redis.Error(rdsErr.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return redigoReply(reply), nil
+}
+
+// ForEachMaster 实现ClusterClient接口
+func (c goRedisClusterClient) ForEachMaster(ctx context.Context, fn func(ctx context.Context, node Client) error) error {
+	return c.cluster.ForEachMaster(ctx, func(ctx context.Context, client *goredis.Client) error {
+		return fn(ctx, goRedisClient{client: client})
+	})
+}
+
+// redigoReply 将go-redis的回复转为redigo的类型。批量字符串在go-redis中为string，转为[]byte
+func redigoReply(reply interface{}) interface{} {
+	switch reply := reply.(type) {
+	case string:
+		return []byte(reply)
+	case []interface{}:
+		converted := make([]interface{}, len(reply))
+		for idx, item := range reply {
+			converted[idx] = redigoReply(item)
+		}
+		return converted
+	default:
+		return reply
+	}
+}
@@ -0,0 +1,65 @@
+package rdscache
+
+// wencan
+// 2026-10-19
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex"
+)
+
+func TestRdsCacheWithGoRedis(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	client := goredis.NewClient(&goredis.Options{Addr: s.Addr(), DB: 1})
+	defer client.Close()
+
+	cache := NewRdsCacheWithClient(NewGoRedisClient(client), RdsKeyPrefixOption("prefix"), RdsDefaultTTLOption(time.Minute))
+	assert.Implements(t, (*cachex.DeletableStorage)(nil), cache)
+
+	err = cache.Set(ctx, "exists", "exists")
+	if assert.NoError(t, err) {
+		var value string
+		err = cache.Get(ctx, "exists", &value)
+		assert.NoError(t, err)
+		assert.Equal(t, "exists", value)
+		assert.Equal(t, time.Minute, s.DB(1).TTL("prefix:exists"))
+	}
+
+	var value string
+	err = cache.Get(ctx, "non-exists", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+
+	err = cache.Del(ctx, "exists")
+	if assert.NoError(t, err) {
+		err = cache.Get(ctx, "exists", &value)
+		assert.Implements(t, (*cachex.NotFound)(nil), err)
+	}
+
+	// SCAN的回复转为redigo的类型；miniredis不支持UNLINK，改用DEL
+	for _, key := range []string{"user:1", "user:2", "order:1"} {
+		err = cache.Set(ctx, key, key)
+		assert.NoError(t, err)
+	}
+	err = cache.DelPattern(ctx, "user:*")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"prefix:order:1"}, s.DB(1).Keys())
+	}
+}
+
+func TestRedigoReply(t *testing.T) {
+	reply := redigoReply([]interface{}{"0", []interface{}{"a", "b"}, int64(1), nil})
+	assert.Equal(t, []interface{}{[]byte("0"), []interface{}{[]byte("a"), []byte("b")}, int64(1), nil}, reply)
+}
@@ -0,0 +1,65 @@
+/*
+ * 租约（memcache风格）
+ * 没找到时写入保留租约有效期的租约占位，占位的版本即租约，
+ * 删除key同时删除占位，凭租约比较并写入
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package rdscache
+
+import (
+	"context"
+	"time"
+)
+
+// RdsLeaseOption 配置租约，用于GetWithLease、SetWithLease（实现cachex.LeasableStorage接口），隐含RdsVersionOption。
+// 没找到时写入保留leaseTTL的租约占位（格式同删除标记，读取视为没找到），多个进程共享同一个占位的租约；
+// 数据已过期时，数据的版本即租约。Del删除占位使租约失效，查询耗时超过leaseTTL的结果也会被放弃。
+// 租约占位视为不存在：Add（仅不存在时写入）覆盖占位，使租约失效。
+func RdsLeaseOption(leaseTTL time.Duration) RdsOption {
+	return RdsOption{func(options *rdsOptions) {
+		options.versioned = true
+		options.leaseTTL = leaseTTL
+	}}
+}
+
+// GetWithLease 获取缓存数据和租约，实现cachex.LeasableStorage接口。错误语义同Get。
+// 未配置RdsLeaseOption时，租约即版本令牌，同GetWithVersion
+func (c *RdsCache) GetWithLease(ctx context.Context, key, value interface{}) (uint64, error) {
+	_, version, err := c.get(ctx, key, value)
+	if c.leaseTTL == 0 || version != 0 {
+		return version, err
+	}
+	if _, ok := err.(NotFound); !ok {
+		return version, err
+	}
+
+	skey, err := c.stringKey(key)
+	if err != nil {
+		return 0, err
+	}
+	placeholder, err := wrapVersionToken(nil)
+	if err != nil {
+		return 0, err
+	}
+	reply, err := c.client.Do(ctx, "SET", skey, placeholder, "NX", "PX", int(c.leaseTTL/time.Millisecond))
+	if err != nil {
+		return 0, err
+	}
+	if reply != nil {
+		lease, _, _ := unwrapVersionToken(placeholder)
+		return lease, notFound
+	}
+
+	// 其它进程已写入数据或租约占位
+	_, version, err = c.get(ctx, key, value)
+	return version, err
+}
+
+// SetWithLease 凭租约设置缓存数据，实现cachex.LeasableStorage接口。TTL为0时使用默认TTL。
+// 租约已失效时放弃写入，返回false。同CompareAndSet
+func (c *RdsCache) SetWithLease(ctx context.Context, key, value interface{}, lease uint64, TTL time.Duration) (bool, error) {
+	return c.CompareAndSet(ctx, key, value, lease, TTL)
+}
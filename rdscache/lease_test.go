@@ -0,0 +1,106 @@
+package rdscache
+
+// wencan
+// 2026-10-19
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex"
+)
+
+func TestRdsCacheLease(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	// miniredis的lua脚本总是在DB 0执行
+	cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{}, RdsDefaultTTLOption(time.Minute), RdsLeaseOption(time.Second*10))
+	assert.Implements(t, (*cachex.LeasableStorage)(nil), cache)
+	// 另一个进程
+	other := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{}, RdsDefaultTTLOption(time.Minute), RdsLeaseOption(time.Second*10))
+
+	var value string
+	lease, err := cache.GetWithLease(ctx, "key", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	assert.NotEqual(t, uint64(0), lease)
+	assert.Equal(t, time.Second*10, s.TTL("key"))
+
+	// 租约占位视为没找到，进程间共享租约
+	err = other.Get(ctx, "key", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	shared, err := other.GetWithLease(ctx, "key", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	assert.Equal(t, lease, shared)
+
+	written, err := cache.SetWithLease(ctx, "key", "first", lease, 0)
+	assert.NoError(t, err)
+	assert.True(t, written)
+	assert.Equal(t, time.Minute, s.TTL("key"))
+
+	// 租约已使用
+	written, err = other.SetWithLease(ctx, "key", "second", shared, 0)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	// 没找到后，查询期间被其它进程删除
+	lease, err = cache.GetWithLease(ctx, "deleted", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	err = other.Del(ctx, "deleted")
+	assert.NoError(t, err)
+	written, err = cache.SetWithLease(ctx, "deleted", "stale", lease, 0)
+	assert.NoError(t, err)
+	assert.False(t, written)
+	assert.False(t, s.Exists("deleted"))
+
+	// 租约过期
+	lease, err = cache.GetWithLease(ctx, "slow", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	s.FastForward(time.Second * 11)
+	written, err = cache.SetWithLease(ctx, "slow", "stale", lease, 0)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	err = other.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", value)
+}
+
+func TestRdsCacheCachexLease(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{}, RdsLeaseOption(time.Minute))
+
+	var c *cachex.Cachex
+	query := func(ctx context.Context, key, value interface{}) error {
+		// 查询期间数据被更新并失效
+		err := c.Del(ctx, key)
+		assert.NoError(t, err)
+
+		*value.(*string) = "stale"
+		return nil
+	}
+	c = cachex.NewCachex(cache, cachex.QueryFunc(query))
+
+	var value string
+	err = c.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "stale", value)
+
+	// 过时的查询结果没有写入
+	assert.False(t, s.Exists("key"))
+}
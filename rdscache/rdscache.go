@@ -9,21 +9,26 @@ package rdscache
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"net"
 	"strings"
 	"time"
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/vmihailenco/msgpack"
+	"github.com/wencan/cachex"
 )
 
 var (
-	// Marshal 数据序列化函数
+	// Marshal 数据序列化函数。
+	// 仅作用于未配置编解码器（RdsCodecOption）的RdsCache。
+	//
+	// Deprecated: 使用RdsCodecOption为每个RdsCache配置编解码器。
 	Marshal = msgpack.Marshal
 
-	// Unmarshal 数据反序列化函数
+	// Unmarshal 数据反序列化函数。
+	// 仅作用于未配置编解码器（RdsCodecOption）的RdsCache。
+	//
+	// Deprecated: 使用RdsCodecOption为每个RdsCache配置编解码器。
 	Unmarshal = msgpack.Unmarshal
 )
 
@@ -36,15 +41,60 @@ func (NotFound) Error() string {
 	return "not found"
 }
 
+// Expired 数据已过期错误
+type Expired struct{}
+
+// Expired 实现cachex.Expired错误接口
+func (Expired) Expired() {}
+func (Expired) Error() string {
+	return "expired"
+}
+
 var notFound = NotFound{}
+var expired = Expired{}
 
 // RdsCache redis存储实现
 type RdsCache struct {
-	rdsPool *redis.Pool
+	client Client
+
+	// clusterClient 集群客户端，client不是集群客户端时为nil
+	clusterClient ClusterClient
 
 	keyPrefix string
 
+	hashTag func(key string) string
+
 	defaultTTL time.Duration
+
+	jitter cachex.Jitter
+
+	keyFunc cachex.KeyFunc
+
+	codec Codec
+
+	// compress 是否处理压缩头
+	compress          bool
+	compression       Compression
+	compressThreshold int
+
+	keyProvider KeyProvider
+
+	staleTTL time.Duration
+
+	upgrade UpgradeFunc
+
+	scanCount    int
+	scanInterval time.Duration
+
+	// versioned 是否写入版本头
+	versioned    bool
+	tombstoneTTL time.Duration
+
+	// leaseTTL 租约有效期，为0时不发放租约
+	leaseTTL time.Duration
+
+	// noUnlink redis不支持UNLINK命令
+	noUnlink int32
 }
 
 // PoolConfig redis池连接参数
@@ -69,7 +119,34 @@ type PoolConfig struct {
 type rdsOptions struct {
 	keyPrefix string
 
+	hashTag func(key string) string
+
 	defaultTTL time.Duration
+
+	jitter cachex.Jitter
+
+	keyFunc cachex.KeyFunc
+
+	codec Codec
+
+	// compress 是否处理压缩头
+	compress          bool
+	compression       Compression
+	compressThreshold int
+
+	keyProvider KeyProvider
+
+	staleTTL time.Duration
+
+	upgrade UpgradeFunc
+
+	scanCount    int
+	scanInterval time.Duration
+
+	versioned    bool
+	tombstoneTTL time.Duration
+
+	leaseTTL time.Duration
 }
 
 // RdsOption rdscache配置
@@ -84,6 +161,15 @@ func RdsKeyPrefixOption(keyPrefix string) RdsOption {
 	}}
 }
 
+// RdsHashTagOption 配置hash tag。hashTag根据key（不含前缀，见cachex.KeyString）返回tag，
+// 返回非空的tag时，redis key为"前缀:{tag}key"，tag相同的key在集群中位于同一个slot。
+// 配置后DelPattern的模式匹配的是"{tag}key"。
+func RdsHashTagOption(hashTag func(key string) string) RdsOption {
+	return RdsOption{func(options *rdsOptions) {
+		options.hashTag = hashTag
+	}}
+}
+
 // RdsDefaultTTLOption 配置key默认生存时间
 func RdsDefaultTTLOption(defaultTTL time.Duration) RdsOption {
 	return RdsOption{func(options *rdsOptions) {
@@ -91,9 +177,99 @@ func RdsDefaultTTLOption(defaultTTL time.Duration) RdsOption {
 	}}
 }
 
+// RdsTTLJitterOption 配置写入时的TTL抖动策略，作用于每一次写入（Cachex已抖动过的TTL除外，见cachex.JitterTTL）
+func RdsTTLJitterOption(jitter cachex.Jitter) RdsOption {
+	return RdsOption{func(options *rdsOptions) {
+		options.jitter = jitter
+	}}
+}
+
+// RdsKeyFuncOption 配置key规范化函数。规范化后的key再转为字符串，见cachex.KeyString
+func RdsKeyFuncOption(keyFunc cachex.KeyFunc) RdsOption {
+	return RdsOption{func(options *rdsOptions) {
+		options.keyFunc = keyFunc
+	}}
+}
+
+// RdsCodecOption 配置数据编解码器。未配置时使用包变量Marshal、Unmarshal（msgpack）
+func RdsCodecOption(codec Codec) RdsOption {
+	return RdsOption{func(options *rdsOptions) {
+		options.codec = codec
+	}}
+}
+
+// RdsCompressOption 配置压缩。编码后不小于threshold字节的数据按compression压缩。
+// 压缩的数据带有标记算法的头，读取时根据头解压，因此压缩与不压缩的数据可以共存，更换算法也无需清空缓存。
+// 读取其它配置了压缩的RdsCache写入的数据，也需要配置该选项（compression可为NoCompression）。
+func RdsCompressOption(compression Compression, threshold int) RdsOption {
+	return RdsOption{func(options *rdsOptions) {
+		options.compress = true
+		options.compression = compression
+		options.compressThreshold = threshold
+	}}
+}
+
+// RdsEncryptOption 配置加密。数据使用AES-GCM加密，以redis key作为附加认证数据。
+// 加密的数据带有密钥ID，轮换密钥后旧数据仍可解密。无法解密的数据视为没找到。
+func RdsEncryptOption(provider KeyProvider) RdsOption {
+	return RdsOption{func(options *rdsOptions) {
+		options.keyProvider = provider
+	}}
+}
+
+// RdsStaleTTLOption 配置过期数据的保留时长。
+// 数据以信封保存，信封记录写入时间、逻辑过期时间（写入时间+TTL）和物理过期时间（逻辑过期时间+staleTTL），redis的TTL设为物理过期时间。
+// 逻辑过期后、物理过期前，Get返回过期数据和实现了cachex.Expired接口的错误，以支持cachex的UseStaleWhenError。
+// 没有信封的旧数据视为未过期。Add、Replace（见SetWithTTLAndMode）将逻辑过期的数据视为不存在。
+func RdsStaleTTLOption(staleTTL time.Duration) RdsOption {
+	return RdsOption{func(options *rdsOptions) {
+		options.staleTTL = staleTTL
+	}}
+}
+
+// RdsUpgradeOption 配置数据升级函数。
+// 读取数据时，数据的版本与值类型的当前版本（未实现Versioned接口为空字符串）不一致的，交给升级函数处理；未配置时视为没找到
+func RdsUpgradeOption(upgrade UpgradeFunc) RdsOption {
+	return RdsOption{func(options *rdsOptions) {
+		options.upgrade = upgrade
+	}}
+}
+
+// RdsScanOption 配置DelPattern、Clear的速率。
+// 每批SCAN的COUNT为count（默认100），批次之间间隔interval，以免长时间占用redis
+func RdsScanOption(count int, interval time.Duration) RdsOption {
+	return RdsOption{func(options *rdsOptions) {
+		options.scanCount = count
+		options.scanInterval = interval
+	}}
+}
+
+// RdsVersionOption 配置版本，用于CompareAndSet（实现cachex.CASStorage接口）。
+// 每次写入的数据带有随机的版本头；tombstoneTTL大于0时，Del写入保留tombstoneTTL的删除标记代替删除，
+// 使删除前（包括没找到时）得到的版本失效。tombstoneTTL应大于查询的最长耗时。
+// 未配置时写入的数据没有版本头（以版本头开头的数据除外），CompareAndSet不比较版本。没有版本头的数据和不存在的key版本均为0。DelPattern、Clear不写入删除标记。
+func RdsVersionOption(tombstoneTTL time.Duration) RdsOption {
+	return RdsOption{func(options *rdsOptions) {
+		options.versioned = true
+		options.tombstoneTTL = tombstoneTTL
+	}}
+}
+
 // NewRdsCache 创建redis缓存对象
 // 内部创建redis连接池
 func NewRdsCache(ctx context.Context, network, address string, poolCfg PoolConfig, options ...RdsOption) *RdsCache {
+	return NewRdsCacheWithPool(newPool(network, address, poolCfg), options...)
+}
+
+// NewRdsClusterCache 创建redis集群缓存对象
+// addrs为部分集群节点的地址，用于获取slot分布；内部为每个节点创建连接池，poolCfg作用于每个连接池，集群不支持DB。
+// 命令按key的hash slot路由，跟随MOVED、ASK重定向；多key命令按slot拆分
+func NewRdsClusterCache(ctx context.Context, addrs []string, poolCfg PoolConfig, options ...RdsOption) *RdsCache {
+	return NewRdsCacheWithClient(NewRedigoClusterClient(addrs, poolCfg), options...)
+}
+
+// newPool 按配置创建redis连接池
+func newPool(network, address string, poolCfg PoolConfig) *redis.Pool {
 	var opts []redis.DialOption
 	if poolCfg.Dial != nil {
 		opts = append(opts, redis.DialNetDial(poolCfg.Dial))
@@ -117,34 +293,71 @@ func NewRdsCache(ctx context.Context, network, address string, poolCfg PoolConfi
 	rdsPool.Wait = poolCfg.Wait
 	rdsPool.MaxConnLifetime = poolCfg.MaxConnLifetime
 
-	return NewRdsCacheWithPool(rdsPool, options...)
+	return rdsPool
 }
 
 // NewRdsCacheWithPool 创建redis缓存对象
 // 使用现有redis连接池
 func NewRdsCacheWithPool(rdsPool *redis.Pool, options ...RdsOption) *RdsCache {
+	return NewRdsCacheWithClient(NewRedigoClient(rdsPool), options...)
+}
+
+// NewRdsCacheWithClient 创建redis缓存对象
+// 使用现有redis客户端，如NewRedigoClient、NewGoRedisClient适配的客户端
+func NewRdsCacheWithClient(client Client, options ...RdsOption) *RdsCache {
 	var opts rdsOptions
 	for _, option := range options {
 		option.f(&opts)
 	}
+	if opts.codec == nil {
+		opts.codec = varsCodec{}
+	}
+
+	clusterClient, _ := client.(ClusterClient)
 
 	return &RdsCache{
-		rdsPool:    rdsPool,
+		client:        client,
+		clusterClient: clusterClient,
+
 		keyPrefix:  opts.keyPrefix,
+		hashTag:    opts.hashTag,
 		defaultTTL: opts.defaultTTL,
+		jitter:     opts.jitter,
+		keyFunc:    opts.keyFunc,
+		codec:      opts.codec,
+
+		compress:          opts.compress,
+		compression:       opts.compression,
+		compressThreshold: opts.compressThreshold,
+
+		keyProvider: opts.keyProvider,
+
+		staleTTL: opts.staleTTL,
+
+		upgrade: opts.upgrade,
+
+		scanCount:    opts.scanCount,
+		scanInterval: opts.scanInterval,
+
+		versioned:    opts.versioned,
+		tombstoneTTL: opts.tombstoneTTL,
+
+		leaseTTL: opts.leaseTTL,
 	}
 }
 
 // stringKey 将interface{} key转为字符串并加上前缀，不支持类型返回错误
+// 结构体、切片、map等key编码为确定的字符串，见cachex.KeyString
 func (c *RdsCache) stringKey(key interface{}) (string, error) {
-	var skey string
-	switch t := key.(type) {
-	case fmt.Stringer:
-		skey = t.String()
-	case string, []byte, int, int32, int64, uint, uint32, uint64, float32, float64, bool:
-		skey = fmt.Sprint(key)
-	default:
-		return "", errors.New("key type is unacceptable")
+	skey, err := cachex.KeyStringFunc(c.keyFunc, key)
+	if err != nil {
+		return "", err
+	}
+
+	if c.hashTag != nil {
+		if tag := c.hashTag(skey); tag != "" {
+			skey = "{" + tag + "}" + skey
+		}
 	}
 
 	if c.keyPrefix != "" {
@@ -158,61 +371,257 @@ func (c *RdsCache) Set(ctx context.Context, key, value interface{}) error {
 	return c.SetWithTTL(ctx, key, value, c.defaultTTL)
 }
 
-// SetWithTTL 设置缓存数据，并定制TTL
+// SetWithTTL 设置缓存数据，并定制TTL。覆盖已存在的数据，包括RdsStaleTTLOption保留的过期数据
 func (c *RdsCache) SetWithTTL(ctx context.Context, key, value interface{}, TTL time.Duration) error {
+	_, err := c.SetWithTTLAndMode(ctx, key, value, TTL, cachex.WriteOverwrite)
+	return err
+}
+
+// SetWithMode 按写入模式设置缓存数据，实现cachex.ModeWritableStorage接口。返回是否写入
+func (c *RdsCache) SetWithMode(ctx context.Context, key, value interface{}, mode cachex.WriteMode) (bool, error) {
+	return c.SetWithTTLAndMode(ctx, key, value, c.defaultTTL, mode)
+}
+
+// Add 仅数据不存在时设置缓存数据（SET NX），返回是否写入
+func (c *RdsCache) Add(ctx context.Context, key, value interface{}) (bool, error) {
+	return c.SetWithMode(ctx, key, value, cachex.WriteAdd)
+}
+
+// SetWithTTLAndMode 按写入模式设置缓存数据，并定制TTL。返回是否写入。
+// 配置了RdsStaleTTLOption时，逻辑过期的数据视为不存在（与lrucache一致），先读取判断，再通过lua脚本在数据未被修改时写入。
+// 配置了RdsVersionOption或RdsLeaseOption时，通过lua脚本写入，删除标记和租约占位视为不存在
+func (c *RdsCache) SetWithTTLAndMode(ctx context.Context, key, value interface{}, TTL time.Duration, mode cachex.WriteMode) (bool, error) {
 	skey, err := c.stringKey(key)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	data, err := Marshal(value)
+	data, rdsTTL, err := c.encode(ctx, skey, value, TTL)
 	if err != nil {
-		return err
+		return false, err
+	}
+
+	if c.staleTTL > 0 && mode != cachex.WriteOverwrite {
+		return c.setIfExists(ctx, skey, data, rdsTTL, mode == cachex.WriteReplace)
+	}
+
+	if c.versioned && mode != cachex.WriteOverwrite {
+		flag := "NX"
+		if mode == cachex.WriteReplace {
+			flag = "XX"
+		}
+		written, err := c.evalScript(ctx, modeScript, modeScriptSHA1, skey, versionTokenMagic[:], flag, data, int(rdsTTL/time.Millisecond))
+		if err != nil {
+			return false, err
+		}
+		return written == 1, nil
 	}
 
-	conn, err := c.rdsPool.GetContext(ctx)
+	args := []interface{}{skey, data}
+	switch mode {
+	case cachex.WriteAdd:
+		args = append(args, "NX")
+	case cachex.WriteReplace:
+		args = append(args, "XX")
+	}
+	if rdsTTL != 0 {
+		args = append(args, "PX", int(rdsTTL/time.Millisecond))
+	}
+
+	// NX、XX条件不满足时，回复为nil
+	reply, err := c.client.Do(ctx, "SET", args...)
 	if err != nil {
-		return err
+		return false, err
 	}
-	defer conn.Close()
 
-	if TTL != 0 {
-		_, err = conn.Do("SET", skey, data, "NX", "PX", int(TTL/time.Millisecond))
-	} else {
-		_, err = conn.Do("SET", skey, data)
+	return reply != nil, nil
+}
+
+// setIfExists 数据存在与否同exists时写入，返回是否写入。删除标记、租约占位、无法解密和逻辑过期的数据视为不存在。
+// 读取判断后，通过lua脚本在数据未被修改时写入；期间数据被修改则重新读取判断
+func (c *RdsCache) setIfExists(ctx context.Context, skey string, data []byte, rdsTTL time.Duration, exists bool) (bool, error) {
+	for {
+		current, err := redis.Bytes(c.client.Do(ctx, "GET", skey))
+		found := err == nil
+		if err != nil && err != redis.ErrNil {
+			return false, err
+		}
+
+		present := false
+		if found {
+			_, env, wrapped, _, err := c.decode(skey, current)
+			if err != nil && err != notFound {
+				return false, err
+			}
+			present = err == nil && !(wrapped && env.expired(time.Now()))
+		}
+		if present != exists {
+			return false, nil
+		}
+
+		expected := 0
+		if found {
+			expected = 1
+		}
+		written, err := c.evalScript(ctx, swapScript, swapScriptSHA1, skey, expected, current, data, int(rdsTTL/time.Millisecond))
+		if err != nil {
+			return false, err
+		}
+		if written == 1 {
+			return true, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
 	}
+}
+
+// encode 编码要写入的数据：序列化、装入信封、压缩、加密、加上版本头。返回数据和redis的TTL
+func (c *RdsCache) encode(ctx context.Context, skey string, value interface{}, TTL time.Duration) ([]byte, time.Duration, error) {
+	data, err := c.marshalValue(value)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 
-	return nil
+	TTL = cachex.JitterTTL(ctx, c.jitter, TTL)
+
+	// redis的TTL
+	rdsTTL := TTL
+	if c.staleTTL > 0 {
+		now := time.Now()
+		env := envelope{
+			writeTime: now.UnixNano(),
+		}
+		if TTL != 0 {
+			rdsTTL = TTL + c.staleTTL
+			env.softExpire = now.Add(TTL).UnixNano()
+			env.hardExpire = now.Add(rdsTTL).UnixNano()
+		}
+		data = wrapEnvelope(env, data)
+	}
+
+	if c.compress {
+		data, err = compress(c.compression, c.compressThreshold, data)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	if c.keyProvider != nil {
+		data, err = encrypt(c.keyProvider, data, []byte(skey))
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	if c.versioned || hasVersionToken(data) {
+		// 未配置版本时，以版本头开头的数据（如RawCodec的原始字节）也加上版本头，避免读取时误判
+		data, err = wrapVersionToken(data)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return data, rdsTTL, nil
 }
 
-// Get 获取缓存数据
+// Get 获取缓存数据。配置了RdsStaleTTLOption时，数据已逻辑过期返回过期数据和Expired错误
 func (c *RdsCache) Get(ctx context.Context, key, value interface{}) error {
-	skey, err := c.stringKey(key)
-	if err != nil {
-		return err
+	_, _, err := c.get(ctx, key, value)
+	return err
+}
+
+// GetWithAge 获取缓存数据和数据的年龄，实现cachex.AgeableStorage接口。
+// 数据的写入时间记录在信封中：未配置RdsStaleTTLOption时返回cachex.ErrNotSupported；没有信封的旧数据年龄未知，视为没找到
+func (c *RdsCache) GetWithAge(ctx context.Context, key, value interface{}) (time.Duration, error) {
+	if c.staleTTL <= 0 {
+		return 0, cachex.ErrNotSupported
+	}
+
+	age, _, err := c.get(ctx, key, value)
+	if age == unknownAge {
+		switch err.(type) {
+		case nil, Expired:
+			return 0, notFound
+		}
+		return 0, err
 	}
+	return age, err
+}
+
+// unknownAge 没有信封的数据的年龄
+const unknownAge time.Duration = -1
 
-	conn, err := c.rdsPool.GetContext(ctx)
+// get 获取缓存数据、数据的年龄和版本。没有信封的数据，年龄返回unknownAge
+func (c *RdsCache) get(ctx context.Context, key, value interface{}) (time.Duration, uint64, error) {
+	skey, err := c.stringKey(key)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
-	data, err := redis.Bytes(conn.Do("GET", skey))
-	conn.Close()
+
+	data, err := redis.Bytes(c.client.Do(ctx, "GET", skey))
 	if err == redis.ErrNil {
-		return notFound
+		return 0, 0, notFound
 	} else if err != nil {
-		return err
+		return 0, 0, err
 	}
 
-	err = Unmarshal(data, value)
+	version, env, wrapped, data, err := c.decode(skey, data)
+	if err == notFound {
+		return 0, version, err
+	} else if err != nil {
+		return 0, 0, err
+	}
+
+	err = c.unmarshalValue(data, value)
 	if err != nil {
-		return err
+		if _, ok := err.(NotFound); ok {
+			return 0, version, err
+		}
+		return 0, 0, err
 	}
 
-	return nil
+	if !wrapped {
+		return unknownAge, version, nil
+	}
+	now := time.Now()
+	if env.expired(now) {
+		// 返回过期数据同时，返回expired错误
+		return env.age(now), version, expired
+	}
+	return env.age(now), version, nil
+}
+
+// decode 解开写入时的包装：版本头、加密、压缩、信封，返回版本、信封和编码后的数据。没有信封的数据，wrapped返回false。
+// 删除标记、租约占位和无法解密的数据返回notFound
+func (c *RdsCache) decode(skey string, data []byte) (version uint64, env envelope, wrapped bool, unwrapped []byte, err error) {
+	// 版本头在最外层，未配置RdsVersionOption也识别，以便读取配置前写入的数据
+	version, data, ok := unwrapVersionToken(data)
+	if ok && len(data) == 0 {
+		// 删除标记
+		return version, envelope{}, false, nil, notFound
+	}
+
+	if c.keyProvider != nil {
+		data, err = decrypt(c.keyProvider, data, []byte(skey))
+		if err != nil {
+			// 无法解密的数据，如未加密的旧数据、密钥已删除，视为没找到
+			return version, envelope{}, false, nil, notFound
+		}
+	}
+
+	if c.compress {
+		data, err = decompress(data)
+		if err != nil {
+			return 0, envelope{}, false, nil, err
+		}
+	}
+
+	if c.staleTTL > 0 {
+		env, data, wrapped, err = unwrapEnvelope(data)
+		if err != nil {
+			return 0, envelope{}, false, nil, err
+		}
+	}
+	return version, env, wrapped, data, nil
 }
 
 // Del 删除缓存数据
@@ -225,13 +634,33 @@ func (c *RdsCache) Del(ctx context.Context, keys ...interface{}) error {
 		}
 	}
 
-	conn, err := c.rdsPool.GetContext(ctx)
-	if err != nil {
-		return err
+	if c.versioned && c.tombstoneTTL > 0 {
+		// 写入删除标记，使删除前得到的版本失效
+		for _, skey := range keys {
+			tombstone, err := wrapVersionToken(nil)
+			if err != nil {
+				return err
+			}
+			_, err = c.client.Do(ctx, "SET", skey, tombstone, "PX", int(c.tombstoneTTL/time.Millisecond))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if c.clusterClient != nil {
+		// 集群不支持跨slot的多key命令
+		for _, group := range groupBySlot(keys) {
+			_, err = c.client.Do(ctx, "DEL", group...)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
 	}
-	defer conn.Close()
 
-	_, err = conn.Do("DEL", keys...)
+	_, err = c.client.Do(ctx, "DEL", keys...)
 	if err != nil {
 		return err
 	}
@@ -5,6 +5,8 @@ package rdscache
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
 	"testing"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/gomodule/redigo/redis"
 	"github.com/stretchr/testify/assert"
 	"github.com/wencan/cachex"
+	"github.com/wencan/cachex/lrucache"
 )
 
 func TestRdsCache(t *testing.T) {
@@ -185,3 +188,372 @@ func TestRdsCacheStringerKey(t *testing.T) {
 		assert.NoError(t, err)
 	}
 }
+
+func TestRdsCacheTTLJitter(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	jitter := cachex.NewPercentJitter(0.1, rand.NewSource(1))
+	cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1}, RdsDefaultTTLOption(time.Minute), RdsTTLJitterOption(jitter))
+
+	err = cache.Set(ctx, "exists", "exists")
+	if assert.NoError(t, err) {
+		ttl := s.DB(1).TTL("exists")
+		assert.True(t, ttl >= time.Minute*9/10)
+		assert.True(t, ttl <= time.Minute*11/10)
+		assert.NotEqual(t, time.Minute, ttl)
+	}
+
+	// Cachex抖动过的TTL不再抖动
+	c := cachex.NewCachex(cache, nil)
+	c.UseTTLJitter(cachex.JitterFunc(func(TTL time.Duration) time.Duration {
+		return TTL + time.Second
+	}))
+	err = c.SetWithTTL(ctx, "jittered", "jittered", time.Hour)
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Hour+time.Second, s.DB(1).TTL("jittered"))
+	}
+}
+
+type testStructKey struct {
+	ID   int
+	Tags []string
+}
+
+func TestRdsCacheStructKey(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1}, RdsKeyPrefixOption("prefix"))
+
+	key := testStructKey{ID: 1, Tags: []string{"a", "b"}}
+	err = cache.Set(ctx, key, "exists")
+	if assert.NoError(t, err) {
+		_, err := s.DB(1).Get(`prefix:rdscache.testStructKey{ID:1,Tags:["a","b"]}`)
+		assert.NoError(t, err)
+
+		var value string
+		err = cache.Get(ctx, testStructKey{ID: 1, Tags: []string{"a", "b"}}, &value)
+		assert.NoError(t, err)
+		assert.Equal(t, "exists", value)
+	}
+
+	_, err = cache.stringKey(make(chan int))
+	assert.Equal(t, cachex.ErrUnsupportedKey, err)
+}
+
+func TestRdsCacheStale(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1}, RdsDefaultTTLOption(time.Millisecond*50), RdsStaleTTLOption(time.Minute))
+	assert.Implements(t, (*cachex.AgeableStorage)(nil), cache)
+
+	// 兼容没有信封的旧数据，年龄未知视为没找到
+	s.DB(1).Set("legacy", "\xa6legacy")
+	var legacy string
+	err = cache.Get(ctx, "legacy", &legacy)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "legacy", legacy)
+	}
+	_, err = cache.GetWithAge(ctx, "legacy", &legacy)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+
+	// 未配置过期数据保留时长，不支持获取年龄
+	plain := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1})
+	_, err = plain.GetWithAge(ctx, "legacy", &legacy)
+	assert.Equal(t, cachex.ErrNotSupported, err)
+	err = cachex.NewCachex(plain, nil).Get(ctx, "legacy", &legacy, cachex.GetMaxAgeOption(time.Minute))
+	assert.Equal(t, cachex.ErrNotSupported, err)
+
+	err = cache.Set(ctx, "exists", "exists")
+	if !assert.NoError(t, err) {
+		return
+	}
+	// redis的TTL为物理过期时间
+	assert.Equal(t, time.Minute+time.Millisecond*50, s.DB(1).TTL("exists"))
+
+	var value string
+	age, err := cache.GetWithAge(ctx, "exists", &value)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "exists", value)
+		assert.True(t, age >= 0 && age < time.Millisecond*50)
+	}
+
+	time.Sleep(time.Millisecond * 60)
+
+	// 逻辑过期后，返回过期数据
+	value = ""
+	err = cache.Get(ctx, "exists", &value)
+	assert.Implements(t, (*cachex.Expired)(nil), err)
+	assert.Equal(t, "exists", value)
+
+	// 物理过期后，没找到
+	s.FastForward(time.Minute + time.Millisecond*50)
+	err = cache.Get(ctx, "exists", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+}
+
+func TestRdsCacheStaleRefresh(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	options := map[string][]RdsOption{
+		"plain":     nil,
+		"versioned": {RdsVersionOption(time.Minute)},
+		"lease":     {RdsLeaseOption(time.Minute)},
+	}
+	for name, opts := range options {
+		opts = append(opts, RdsDefaultTTLOption(time.Millisecond*50), RdsStaleTTLOption(time.Minute))
+		// miniredis的lua脚本总是在DB 0执行
+		cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{}, opts...)
+
+		var queried int
+		query := func(ctx context.Context, key, value interface{}) error {
+			queried++
+			*value.(*int) = queried
+			return nil
+		}
+		c := cachex.NewCachex(cache, cachex.QueryFunc(query))
+
+		var value int
+		err = c.Get(ctx, name, &value)
+		assert.NoError(t, err, name)
+		assert.Equal(t, 1, value, name)
+
+		// 逻辑过期后，查询结果覆盖保留的过期数据
+		time.Sleep(time.Millisecond * 60)
+		err = c.Get(ctx, name, &value)
+		assert.NoError(t, err, name)
+		assert.Equal(t, 2, value, name)
+
+		err = cache.Get(ctx, name, &value)
+		assert.NoError(t, err, name)
+		assert.Equal(t, 2, value, name)
+	}
+}
+
+func TestRdsCacheDelPatternAndClear(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1}, RdsKeyPrefixOption("prefix*"), RdsScanOption(2, time.Millisecond))
+	assert.Implements(t, (*cachex.PatternDeletableStorage)(nil), cache)
+	assert.Implements(t, (*cachex.ClearableStorage)(nil), cache)
+
+	for i := 0; i < 5; i++ {
+		err = cache.Set(ctx, fmt.Sprintf("user:%d", i), "user")
+		assert.NoError(t, err)
+	}
+	err = cache.Set(ctx, "order:1", "order")
+	assert.NoError(t, err)
+	s.DB(1).Set("other", "other")
+	s.DB(1).Set("prefixother:user:1", "other")
+
+	err = cache.DelPattern(ctx, "user:*")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"other", "prefix*:order:1", "prefixother:user:1"}, s.DB(1).Keys())
+	}
+
+	err = cache.Clear(ctx)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"other", "prefixother:user:1"}, s.DB(1).Keys())
+	}
+
+	// 可取消
+	for i := 0; i < 5; i++ {
+		err = cache.Set(ctx, fmt.Sprintf("user:%d", i), "user")
+		assert.NoError(t, err)
+	}
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = cache.Clear(cancelCtx)
+	assert.Equal(t, context.Canceled, err)
+
+	// 未配置前缀时不清空，不删除其它应用的key
+	plain := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1})
+	err = plain.Set(ctx, "plain", "plain")
+	assert.NoError(t, err)
+	err = plain.Clear(ctx)
+	assert.Equal(t, cachex.ErrNotSupported, err)
+	assert.True(t, s.DB(1).Exists("other"))
+	assert.True(t, s.DB(1).Exists("plain"))
+}
+
+func TestRdsCacheWriteMode(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1}, RdsDefaultTTLOption(time.Minute))
+	assert.Implements(t, (*cachex.ModeWritableStorage)(nil), cache)
+
+	// 有TTL时，Set也覆盖
+	err = cache.Set(ctx, "key", "first")
+	assert.NoError(t, err)
+	err = cache.Set(ctx, "key", "second")
+	assert.NoError(t, err)
+	var value string
+	err = cache.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", value)
+
+	written, err := cache.Add(ctx, "key", "add")
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	written, err = cache.SetWithMode(ctx, "key", "replace", cachex.WriteReplace)
+	assert.NoError(t, err)
+	assert.True(t, written)
+	err = cache.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "replace", value)
+	assert.Equal(t, time.Minute, s.DB(1).TTL("key"))
+
+	written, err = cache.SetWithMode(ctx, "non-exists", "replace", cachex.WriteReplace)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	written, err = cache.Add(ctx, "non-exists", "add")
+	assert.NoError(t, err)
+	assert.True(t, written)
+	err = cache.Get(ctx, "non-exists", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "add", value)
+}
+
+// testModeStorage 支持写入模式的存储后端
+type testModeStorage interface {
+	cachex.Storage
+	cachex.ModeWritableStorage
+}
+
+// testWriteModeExpired 检查已过期的数据对Add、Replace视为不存在。storage的默认TTL为ttl
+func testWriteModeExpired(t *testing.T, storage testModeStorage, ttl time.Duration) {
+	ctx := context.Background()
+
+	err := storage.Set(ctx, "key", "first")
+	assert.NoError(t, err)
+	written, err := storage.SetWithMode(ctx, "key", "add", cachex.WriteAdd)
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	time.Sleep(ttl + ttl/2)
+	written, err = storage.SetWithMode(ctx, "key", "replace", cachex.WriteReplace)
+	assert.NoError(t, err)
+	assert.False(t, written)
+	written, err = storage.SetWithMode(ctx, "key", "add", cachex.WriteAdd)
+	assert.NoError(t, err)
+	assert.True(t, written)
+
+	var value string
+	err = storage.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "add", value)
+	written, err = storage.SetWithMode(ctx, "key", "replace", cachex.WriteReplace)
+	assert.NoError(t, err)
+	assert.True(t, written)
+}
+
+func TestRdsCacheWriteModeExpired(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+	ttl := time.Millisecond * 50
+
+	// 与lrucache一致，逻辑过期的数据视为不存在
+	testWriteModeExpired(t, lrucache.NewLRUCache(0, ttl), ttl)
+	cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{}, RdsDefaultTTLOption(ttl), RdsStaleTTLOption(time.Minute))
+	testWriteModeExpired(t, cache, ttl)
+	versioned := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{}, RdsDefaultTTLOption(ttl), RdsStaleTTLOption(time.Minute),
+		RdsVersionOption(time.Minute), RdsKeyPrefixOption("versioned"))
+	testWriteModeExpired(t, versioned, ttl)
+}
+
+func TestRdsCacheWriteModeTombstone(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	// miniredis的lua脚本总是在DB 0执行
+	cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{}, RdsDefaultTTLOption(time.Minute), RdsVersionOption(time.Minute))
+
+	err = cache.Set(ctx, "key", "first")
+	assert.NoError(t, err)
+	written, err := cache.Add(ctx, "key", "add")
+	assert.NoError(t, err)
+	assert.False(t, written)
+
+	// 删除标记视为不存在
+	err = cache.Del(ctx, "key")
+	assert.NoError(t, err)
+	assert.True(t, s.Exists("key"))
+	written, err = cache.SetWithMode(ctx, "key", "replace", cachex.WriteReplace)
+	assert.NoError(t, err)
+	assert.False(t, written)
+	written, err = cache.Add(ctx, "key", "add")
+	assert.NoError(t, err)
+	assert.True(t, written)
+	assert.Equal(t, time.Minute, s.TTL("key"))
+
+	var value string
+	err = cache.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "add", value)
+
+	written, err = cache.SetWithMode(ctx, "key", "replace", cachex.WriteReplace)
+	assert.NoError(t, err)
+	assert.True(t, written)
+	err = cache.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "replace", value)
+
+	// 租约占位视为不存在
+	leased := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{}, RdsLeaseOption(time.Minute))
+	lease, err := leased.GetWithLease(ctx, "leased", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	assert.True(t, s.Exists("leased"))
+	written, err = leased.Add(ctx, "leased", "add")
+	assert.NoError(t, err)
+	assert.True(t, written)
+	written, err = leased.SetWithLease(ctx, "leased", "stale", lease, 0)
+	assert.NoError(t, err)
+	assert.False(t, written)
+}
@@ -0,0 +1,102 @@
+/*
+ * 数据的结构版本
+ * 滚动发布新版本的结构体时，旧版本的缓存数据可能反序列化失败，或静默地得到零值字段
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package rdscache
+
+import (
+	"errors"
+)
+
+// Versioned 带结构版本的数据类型。
+// 写入时记录版本，读取时版本不一致的数据交给升级函数处理，未配置升级函数视为没找到。
+// 类型指纹也可作为版本。
+type Versioned interface {
+	// CacheVersion 返回结构版本，长度不超过255字节
+	CacheVersion() string
+}
+
+// UpgradeFunc 数据升级函数。
+// version为数据写入时的版本，没有版本的数据为空字符串；data为编码后的数据，可使用codec解码。
+// 返回错误，数据视为没找到。
+type UpgradeFunc func(codec Codec, version string, data []byte, value interface{}) error
+
+// versionMagic 版本头。第一个字节与压缩头的标记字节相同，msgpack不使用该字节
+var versionMagic = [2]byte{compressMagic, 0xE1}
+
+// ErrVersionTooLong 版本过长
+var ErrVersionTooLong = errors.New("version too long")
+
+// wrapVersion 在编码后的数据前加上版本头
+func wrapVersion(version string, data []byte) ([]byte, error) {
+	if len(version) > 255 {
+		return nil, ErrVersionTooLong
+	}
+
+	wrapped := make([]byte, 0, 3+len(version)+len(data))
+	wrapped = append(wrapped, versionMagic[:]...)
+	wrapped = append(wrapped, byte(len(version)))
+	wrapped = append(wrapped, version...)
+	return append(wrapped, data...), nil
+}
+
+// unwrapVersion 取出版本和编码后的数据。没有版本头的数据，ok返回false，原样返回
+func unwrapVersion(data []byte) (version string, unwrapped []byte, ok bool) {
+	if len(data) < 3 || data[0] != versionMagic[0] || data[1] != versionMagic[1] {
+		return "", data, false
+	}
+	size := int(data[2])
+	if len(data) < 3+size {
+		return "", data, false
+	}
+	return string(data[3 : 3+size]), data[3+size:], true
+}
+
+// marshalValue 编码数据。带结构版本的数据加上版本头。
+// cachex回写的是非指针的值，同时检查值的指针，与解码时检查指针一致。
+// 没有结构版本、但以版本头开头的数据（如RawCodec的原始字节），加上空版本的版本头，避免读取时误判
+func (c *RdsCache) marshalValue(value interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	versioned, ok := value.(Versioned)
+	if !ok {
+		versioned, ok = pointerTo(value).(Versioned)
+	}
+	if !ok {
+		if _, _, hasVersion := unwrapVersion(data); hasVersion {
+			return wrapVersion("", data)
+		}
+		return data, nil
+	}
+	return wrapVersion(versioned.CacheVersion(), data)
+}
+
+// unmarshalValue 解码数据。总是去掉版本头，没有版本头的数据版本为空字符串，没有结构版本的数据类型当前版本为空字符串。
+// 只解码版本一致的数据；版本不一致交给升级函数，未配置升级函数或升级失败返回notFound
+func (c *RdsCache) unmarshalValue(data []byte, value interface{}) error {
+	var current string
+	if versioned, ok := value.(Versioned); ok {
+		current = versioned.CacheVersion()
+	}
+
+	version, data, _ := unwrapVersion(data)
+	if version == current {
+		return c.codec.Unmarshal(data, value)
+	}
+
+	if c.upgrade == nil {
+		return notFound
+	}
+	err := c.upgrade(c.codec, version, data, value)
+	if err != nil {
+		return notFound
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package rdscache
+
+// wencan
+// 2026-10-19
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis"
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex"
+)
+
+type testUserV1 struct {
+	Name string
+}
+
+func (testUserV1) CacheVersion() string {
+	return "user/1"
+}
+
+type testUserV2 struct {
+	FirstName string
+	LastName  string
+}
+
+func (testUserV2) CacheVersion() string {
+	return "user/2"
+}
+
+// testUserV3 指针接收者实现Versioned
+type testUserV3 struct {
+	Name string
+}
+
+func (*testUserV3) CacheVersion() string {
+	return "user/3"
+}
+
+func TestRdsCacheVersionPointerReceiver(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1})
+
+	queries := 0
+	query := func(ctx context.Context, key, value interface{}) error {
+		queries++
+		value.(*testUserV3).Name = "wencan"
+		return nil
+	}
+	c := cachex.NewCachex(cache, cachex.QueryFunc(query))
+
+	// cachex回写非指针的值，也带版本头
+	for i := 0; i < 2; i++ {
+		var value testUserV3
+		err = c.Get(ctx, "user", &value)
+		assert.NoError(t, err)
+		assert.Equal(t, "wencan", value.Name)
+	}
+	assert.Equal(t, 1, queries)
+
+	data, err := s.DB(1).Get("user")
+	assert.NoError(t, err)
+	version, _, ok := unwrapVersion([]byte(data))
+	assert.True(t, ok)
+	assert.Equal(t, "user/3", version)
+}
+
+func TestRdsCacheVersion(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1})
+
+	err = cache.Set(ctx, "user", testUserV1{Name: "wencan"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = cache.Set(ctx, "legacy", map[string]string{"FirstName": "wen"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// 版本一致
+	var v1 testUserV1
+	err = cache.Get(ctx, "user", &v1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "wencan", v1.Name)
+	}
+
+	// 版本不一致，没有升级函数
+	var v2 testUserV2
+	err = cache.Get(ctx, "user", &v2)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	err = cache.Get(ctx, "legacy", &v2)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+
+	// 版本不一致，升级
+	upgrade := func(codec Codec, version string, data []byte, value interface{}) error {
+		if version != "user/1" {
+			return ErrUnsupportedValue
+		}
+		var old testUserV1
+		err := codec.Unmarshal(data, &old)
+		if err != nil {
+			return err
+		}
+		*value.(*testUserV2) = testUserV2{FirstName: old.Name}
+		return nil
+	}
+	upgradable := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1}, RdsUpgradeOption(upgrade))
+	err = upgradable.Get(ctx, "user", &v2)
+	if assert.NoError(t, err) {
+		assert.Equal(t, testUserV2{FirstName: "wencan"}, v2)
+	}
+	err = upgradable.Get(ctx, "legacy", &v2)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+
+	// 读取到没有结构版本的类型，版本不一致视为没找到，不把版本头当作数据解码
+	var plain struct{ Name string }
+	err = cache.Get(ctx, "user", &plain)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	var legacy map[string]string
+	err = cache.Get(ctx, "legacy", &legacy)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{"FirstName": "wen"}, legacy)
+	}
+}
+
+func TestRdsCacheVersionRawValue(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer s.Close()
+
+	ctx := context.Background()
+
+	cache := NewRdsCache(ctx, "tcp", s.Addr(), PoolConfig{DB: 1}, RdsCodecOption(RawCodec{}))
+
+	// 以版本头开头的原始字节，不误判为版本头
+	raw := append(versionMagic[:], 3, 'a', 'b', 'c', 'd')
+	err = cache.Set(ctx, "raw", raw)
+	if assert.NoError(t, err) {
+		var cached []byte
+		err = cache.Get(ctx, "raw", &cached)
+		assert.NoError(t, err)
+		assert.Equal(t, raw, cached)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ * SQL方言
+ * 不同数据库的建表、upsert、分批删除语句
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package sqlcache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Dialect SQL方言
+type Dialect int
+
+const (
+	// SQLite SQLite方言，默认的方言
+	SQLite Dialect = iota
+	// PostgreSQL PostgreSQL方言
+	PostgreSQL
+	// MySQL MySQL方言
+	MySQL
+)
+
+// mysqlMaxKeyLength MySQL的cache_key列（VARCHAR(255)）的最大字符数
+const mysqlMaxKeyLength = 255
+
+// schema 建表语句
+func (d Dialect) schema(table string) []string {
+	switch d {
+	case PostgreSQL:
+		return []string{
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (cache_key TEXT PRIMARY KEY, value BYTEA NOT NULL, expires_at BIGINT NOT NULL)", table),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_expires_at ON %s (expires_at)", table, table),
+		}
+	case MySQL:
+		// MySQL不支持CREATE INDEX IF NOT EXISTS，在建表语句中创建索引
+		return []string{
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (cache_key VARCHAR(255) PRIMARY KEY, value LONGBLOB NOT NULL, expires_at BIGINT NOT NULL, INDEX %s_expires_at (expires_at))", table, table),
+		}
+	default:
+		return []string{
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (cache_key TEXT PRIMARY KEY, value BLOB NOT NULL, expires_at INTEGER NOT NULL)", table),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_expires_at ON %s (expires_at)", table, table),
+		}
+	}
+}
+
+// key 返回可以写入cache_key列的key。
+// MySQL的cache_key列有长度限制，超长的key截断后加上“#”和完整key的sha1，仍保持唯一
+func (d Dialect) key(key string) string {
+	if d != MySQL || utf8.RuneCountInString(key) <= mysqlMaxKeyLength {
+		return key
+	}
+	sum := sha1.Sum([]byte(key))
+	suffix := "#" + hex.EncodeToString(sum[:])
+	// 按字节截断，不截断多字节字符
+	limit := mysqlMaxKeyLength - len(suffix)
+	for limit > 0 && !utf8.RuneStart(key[limit]) {
+		limit--
+	}
+	return key[:limit] + suffix
+}
+
+// upsert 插入或更新语句
+func (d Dialect) upsert(table string) string {
+	switch d {
+	case MySQL:
+		return fmt.Sprintf("INSERT INTO %s (cache_key, value, expires_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE value = VALUES(value), expires_at = VALUES(expires_at)", table)
+	default:
+		return d.rebind(fmt.Sprintf("INSERT INTO %s (cache_key, value, expires_at) VALUES (?, ?, ?) ON CONFLICT (cache_key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at", table))
+	}
+}
+
+// get 查询语句
+func (d Dialect) get(table string) string {
+	return d.rebind(fmt.Sprintf("SELECT value, expires_at FROM %s WHERE cache_key = ?", table))
+}
+
+// del 删除语句
+func (d Dialect) del(table string, n int) string {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+	return d.rebind(fmt.Sprintf("DELETE FROM %s WHERE cache_key IN (%s)", table, placeholders))
+}
+
+// clear 清空语句
+func (d Dialect) clear(table string) string {
+	return fmt.Sprintf("DELETE FROM %s", table)
+}
+
+// deleteExpired 分批删除过期数据的语句。参数为截止时间和批大小
+func (d Dialect) deleteExpired(table string) string {
+	switch d {
+	case MySQL:
+		return fmt.Sprintf("DELETE FROM %s WHERE expires_at > 0 AND expires_at < ? LIMIT ?", table)
+	default:
+		return d.rebind(fmt.Sprintf("DELETE FROM %s WHERE cache_key IN (SELECT cache_key FROM %s WHERE expires_at > 0 AND expires_at < ? LIMIT ?)", table, table))
+	}
+}
+
+// rebind 将“?”占位符转为方言的占位符
+func (d Dialect) rebind(query string) string {
+	if d != PostgreSQL {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for idx := 0; idx < len(query); idx++ {
+		if query[idx] == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		} else {
+			b.WriteByte(query[idx])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,48 @@
+package sqlcache
+
+// wencan
+// 2026-10-19
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialect(t *testing.T) {
+	assert.Equal(t, "SELECT value, expires_at FROM cache WHERE cache_key = ?", SQLite.get("cache"))
+	assert.Equal(t, "SELECT value, expires_at FROM cache WHERE cache_key = $1", PostgreSQL.get("cache"))
+	assert.Equal(t, "DELETE FROM cache WHERE cache_key IN ($1, $2, $3)", PostgreSQL.del("cache", 3))
+	assert.Equal(t, "DELETE FROM cache WHERE cache_key IN (?)", MySQL.del("cache", 1))
+
+	assert.Contains(t, PostgreSQL.upsert("cache"), "VALUES ($1, $2, $3) ON CONFLICT (cache_key) DO UPDATE")
+	assert.Contains(t, MySQL.upsert("cache"), "ON DUPLICATE KEY UPDATE")
+
+	assert.Equal(t, "DELETE FROM cache WHERE expires_at > 0 AND expires_at < ? LIMIT ?", MySQL.deleteExpired("cache"))
+	assert.Contains(t, PostgreSQL.deleteExpired("cache"), "expires_at < $1 LIMIT $2")
+
+	assert.Len(t, MySQL.schema("cache"), 1)
+	assert.Len(t, PostgreSQL.schema("cache"), 2)
+}
+
+func TestDialectKey(t *testing.T) {
+	long := strings.Repeat("k", 300)
+	assert.Equal(t, long, SQLite.key(long))
+	assert.Equal(t, long, PostgreSQL.key(long))
+
+	// MySQL超长的key截断并加上哈希，仍保持唯一
+	key := MySQL.key(long)
+	assert.Len(t, key, mysqlMaxKeyLength)
+	assert.True(t, strings.HasPrefix(key, strings.Repeat("k", 100)))
+	assert.NotEqual(t, key, MySQL.key(long+"k"))
+	assert.Equal(t, strings.Repeat("k", mysqlMaxKeyLength), MySQL.key(strings.Repeat("k", mysqlMaxKeyLength)))
+
+	// 按字符计算长度，不截断多字节字符
+	wide := strings.Repeat("键", mysqlMaxKeyLength)
+	assert.Equal(t, wide, MySQL.key(wide))
+	key = MySQL.key(wide + "键")
+	assert.True(t, utf8.ValidString(key))
+	assert.True(t, utf8.RuneCountInString(key) <= mysqlMaxKeyLength)
+}
@@ -0,0 +1,361 @@
+/*
+ * SQL表存储支持
+ * 缓存数据保存在数据库表(cache_key, value, expires_at)中，通过database/sql读写
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package sqlcache
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/wencan/cachex"
+)
+
+// NotFound 没找到错误
+type NotFound struct{}
+
+// NotFound 实现cachex.NotFound错误接口
+func (NotFound) NotFound() {}
+func (NotFound) Error() string {
+	return "not found"
+}
+
+// Expired 数据已过期错误
+type Expired struct{}
+
+// Expired 实现cachex.Expired错误接口
+func (Expired) Expired() {}
+func (Expired) Error() string {
+	return "expired"
+}
+
+var notFound = NotFound{}
+var expired = Expired{}
+
+// DefaultTable 默认的表名
+const DefaultTable = "cachex"
+
+// DefaultCleanInterval 默认的后台清理间隔
+const DefaultCleanInterval = time.Minute
+
+// DefaultCleanBatch 默认的每批删除的最大行数
+const DefaultCleanBatch = 1000
+
+// SQLCache SQL表缓存类，实现了cachex.DeletableStorage接口、cachex.ClearableStorage接口和cachex.SetWithTTLableStorage接口
+type SQLCache struct {
+	db *sql.DB
+
+	dialect Dialect
+
+	table string
+
+	defaultTTL time.Duration
+
+	// staleTTL 数据过期后保留的时长，保留期间返回过期数据和Expired错误
+	staleTTL time.Duration
+
+	cleanBatch int
+
+	// jitter 写入时的TTL抖动策略
+	jitter cachex.Jitter
+
+	// keyFunc key规范化函数
+	keyFunc cachex.KeyFunc
+
+	codec cachex.Codec
+
+	closeOnce sync.Once
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+type sqlOptions struct {
+	dialect Dialect
+
+	table string
+
+	defaultTTL time.Duration
+
+	staleTTL time.Duration
+
+	cleanInterval time.Duration
+
+	cleanBatch int
+
+	jitter cachex.Jitter
+
+	keyFunc cachex.KeyFunc
+
+	codec cachex.Codec
+}
+
+// SQLOption sqlcache配置
+type SQLOption struct {
+	f func(*sqlOptions)
+}
+
+// SQLDialectOption 配置SQL方言，默认为SQLite
+func SQLDialectOption(dialect Dialect) SQLOption {
+	return SQLOption{func(options *sqlOptions) {
+		options.dialect = dialect
+	}}
+}
+
+// SQLTableOption 配置表名，默认为DefaultTable。表名直接拼接到语句中，不能来自不可信的输入
+func SQLTableOption(table string) SQLOption {
+	return SQLOption{func(options *sqlOptions) {
+		options.table = table
+	}}
+}
+
+// SQLDefaultTTLOption 配置默认TTL。默认不过期
+func SQLDefaultTTLOption(defaultTTL time.Duration) SQLOption {
+	return SQLOption{func(options *sqlOptions) {
+		options.defaultTTL = defaultTTL
+	}}
+}
+
+// SQLStaleTTLOption 配置数据过期后保留的时长。
+// 保留期间Get返回过期数据和Expired错误（查询出错时可使用过期数据），保留期过后由后台删除。默认为0，过期数据保留到下一次清理
+func SQLStaleTTLOption(staleTTL time.Duration) SQLOption {
+	return SQLOption{func(options *sqlOptions) {
+		options.staleTTL = staleTTL
+	}}
+}
+
+// SQLCleanOption 配置后台删除过期数据的间隔和每批删除的最大行数。
+// 默认为DefaultCleanInterval和DefaultCleanBatch。interval小于0时不在后台删除
+func SQLCleanOption(interval time.Duration, batch int) SQLOption {
+	return SQLOption{func(options *sqlOptions) {
+		options.cleanInterval = interval
+		options.cleanBatch = batch
+	}}
+}
+
+// SQLTTLJitterOption 配置写入时的TTL抖动策略，作用于每一次写入（Cachex已抖动过的TTL除外，见cachex.JitterTTL）。默认不抖动
+func SQLTTLJitterOption(jitter cachex.Jitter) SQLOption {
+	return SQLOption{func(options *sqlOptions) {
+		options.jitter = jitter
+	}}
+}
+
+// SQLKeyFuncOption 配置key规范化函数，在转为字符串前调用
+func SQLKeyFuncOption(keyFunc cachex.KeyFunc) SQLOption {
+	return SQLOption{func(options *sqlOptions) {
+		options.keyFunc = keyFunc
+	}}
+}
+
+// SQLCodecOption 配置编解码器。默认使用cachex.MsgpackCodec
+func SQLCodecOption(codec cachex.Codec) SQLOption {
+	return SQLOption{func(options *sqlOptions) {
+		options.codec = codec
+	}}
+}
+
+// NewSQLCache 创建SQL表缓存对象。表不存在时自动创建。使用完毕需调用Close停止后台清理，Close不关闭db
+func NewSQLCache(ctx context.Context, db *sql.DB, options ...SQLOption) (*SQLCache, error) {
+	opts := sqlOptions{
+		table:         DefaultTable,
+		cleanInterval: DefaultCleanInterval,
+		cleanBatch:    DefaultCleanBatch,
+		codec:         cachex.MsgpackCodec{},
+	}
+	for _, option := range options {
+		option.f(&opts)
+	}
+	if opts.cleanBatch <= 0 {
+		opts.cleanBatch = DefaultCleanBatch
+	}
+
+	for _, stmt := range opts.dialect.schema(opts.table) {
+		_, err := db.ExecContext(ctx, stmt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c := &SQLCache{
+		db:         db,
+		dialect:    opts.dialect,
+		table:      opts.table,
+		defaultTTL: opts.defaultTTL,
+		staleTTL:   opts.staleTTL,
+		cleanBatch: opts.cleanBatch,
+		jitter:     opts.jitter,
+		keyFunc:    opts.keyFunc,
+		codec:      opts.codec,
+		done:       make(chan struct{}),
+	}
+	if opts.cleanInterval > 0 {
+		c.wg.Add(1)
+		go c.cleanLoop(opts.cleanInterval)
+	}
+	return c, nil
+}
+
+// Close 停止后台清理
+func (c *SQLCache) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.wg.Wait()
+	})
+	return nil
+}
+
+// stringKey 将interface{} key转为字符串，不支持类型返回错误
+// 结构体、切片、map等key编码为确定的字符串，见cachex.KeyString；超出cache_key列长度的key转为带哈希的短key
+func (c *SQLCache) stringKey(key interface{}) (string, error) {
+	skey, err := cachex.KeyStringFunc(c.keyFunc, key)
+	if err != nil {
+		return "", err
+	}
+	return c.dialect.key(skey), nil
+}
+
+// Set 设置缓存数据
+func (c *SQLCache) Set(ctx context.Context, key, value interface{}) error {
+	return c.SetWithTTL(ctx, key, value, c.defaultTTL)
+}
+
+// SetWithTTL 设置缓存数据，并定制TTL。覆盖已存在的数据（upsert）
+func (c *SQLCache) SetWithTTL(ctx context.Context, key, value interface{}, TTL time.Duration) error {
+	skey, err := c.stringKey(key)
+	if err != nil {
+		return err
+	}
+
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	TTL = cachex.JitterTTL(ctx, c.jitter, TTL)
+
+	// expires_at为unix毫秒数，0为不过期
+	var expiresAt int64
+	if TTL != 0 {
+		expiresAt = toMillis(time.Now().Add(TTL))
+	}
+
+	_, err = c.db.ExecContext(ctx, c.dialect.upsert(c.table), skey, data, expiresAt)
+	return err
+}
+
+// Get 获取缓存数据。数据已过期、在保留期内时，返回过期数据和Expired错误
+func (c *SQLCache) Get(ctx context.Context, key, value interface{}) error {
+	if v := reflect.ValueOf(value); v.Kind() != reflect.Ptr || v.IsNil() {
+		panic("value not is non-nil pointer")
+	}
+
+	skey, err := c.stringKey(key)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	var expiresAt int64
+	err = c.db.QueryRowContext(ctx, c.dialect.get(c.table), skey).Scan(&data, &expiresAt)
+	if err == sql.ErrNoRows {
+		return notFound
+	} else if err != nil {
+		return err
+	}
+
+	now := toMillis(time.Now())
+	if expiresAt != 0 && now > expiresAt+int64(c.staleTTL/time.Millisecond) {
+		// 已超过保留期，等待清理
+		return notFound
+	}
+
+	err = c.codec.Unmarshal(data, value)
+	if err != nil {
+		return err
+	}
+
+	if expiresAt != 0 && now > expiresAt {
+		// 返回过期数据同时，返回expired错误
+		return expired
+	}
+	return nil
+}
+
+// Del 删除缓存数据
+func (c *SQLCache) Del(ctx context.Context, keys ...interface{}) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(keys))
+	for idx, key := range keys {
+		skey, err := c.stringKey(key)
+		if err != nil {
+			return err
+		}
+		args[idx] = skey
+	}
+
+	_, err := c.db.ExecContext(ctx, c.dialect.del(c.table, len(args)), args...)
+	return err
+}
+
+// Clear 清空缓存的数据
+func (c *SQLCache) Clear(ctx context.Context) error {
+	_, err := c.db.ExecContext(ctx, c.dialect.clear(c.table))
+	return err
+}
+
+// DeleteExpired 分批删除已过期、且超过保留期（见SQLStaleTTLOption）的数据，返回删除的行数。
+// 每批一条语句，避免长时间锁表。默认由后台定时调用
+func (c *SQLCache) DeleteExpired(ctx context.Context) (int64, error) {
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		cutoff := toMillis(time.Now().Add(-c.staleTTL))
+		result, err := c.db.ExecContext(ctx, c.dialect.deleteExpired(c.table), cutoff, c.cleanBatch)
+		if err != nil {
+			return total, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < int64(c.cleanBatch) {
+			return total, nil
+		}
+	}
+}
+
+// cleanLoop 定时删除过期数据，直到Close
+func (c *SQLCache) cleanLoop(interval time.Duration) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			// 删除失败等待下一次
+			_, _ = c.DeleteExpired(context.Background())
+		}
+	}
+}
+
+// toMillis 转为unix毫秒数
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
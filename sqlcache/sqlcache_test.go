@@ -0,0 +1,184 @@
+package sqlcache
+
+// wencan
+// 2026-10-19
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex"
+)
+
+// openDB 打开内存SQLite数据库。每个连接是独立的内存数据库，限制为一个连接
+func openDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+func TestSQLCache(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	cache, err := NewSQLCache(ctx, db, SQLDefaultTTLOption(time.Minute))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cache.Close()
+	assert.Implements(t, (*cachex.DeletableStorage)(nil), cache)
+	assert.Implements(t, (*cachex.ClearableStorage)(nil), cache)
+	assert.Implements(t, (*cachex.SetWithTTLableStorage)(nil), cache)
+
+	// 表已存在
+	_, err = NewSQLCache(ctx, db, SQLCleanOption(-1, 0))
+	assert.NoError(t, err)
+
+	var value string
+	err = cache.Get(ctx, "key", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+
+	err = cache.Set(ctx, "key", "value")
+	assert.NoError(t, err)
+	err = cache.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	// upsert
+	err = cache.SetWithTTL(ctx, "key", "forever", 0)
+	assert.NoError(t, err)
+	err = cache.Get(ctx, "key", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "forever", value)
+	var expiresAt int64
+	err = db.QueryRow("SELECT expires_at FROM cachex WHERE cache_key = ?", "key").Scan(&expiresAt)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), expiresAt)
+
+	err = cache.Set(ctx, "other", "other")
+	assert.NoError(t, err)
+	err = cache.Del(ctx, "key", "none")
+	assert.NoError(t, err)
+	err = cache.Get(ctx, "key", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	err = cache.Get(ctx, "other", &value)
+	assert.NoError(t, err)
+
+	err = cache.Clear(ctx)
+	assert.NoError(t, err)
+	err = cache.Get(ctx, "other", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+}
+
+func TestSQLCacheExpired(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	cache, err := NewSQLCache(ctx, db, SQLTableOption("expired_cache"), SQLStaleTTLOption(time.Millisecond*200), SQLCleanOption(-1, 2))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cache.Close()
+
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		err = cache.SetWithTTL(ctx, key, key, time.Millisecond*10)
+		assert.NoError(t, err)
+	}
+	err = cache.SetWithTTL(ctx, "forever", "forever", 0)
+	assert.NoError(t, err)
+
+	// 过期，保留期内返回过期数据
+	time.Sleep(time.Millisecond * 50)
+	var value string
+	err = cache.Get(ctx, "a", &value)
+	assert.Implements(t, (*cachex.Expired)(nil), err)
+	assert.Equal(t, "a", value)
+
+	// 保留期内不删除
+	n, err := cache.DeleteExpired(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+
+	// 超过保留期，分批删除
+	time.Sleep(time.Millisecond * 200)
+	err = cache.Get(ctx, "a", &value)
+	assert.Implements(t, (*cachex.NotFound)(nil), err)
+	n, err = cache.DeleteExpired(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM expired_cache").Scan(&count)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	err = cache.Get(ctx, "forever", &value)
+	assert.NoError(t, err)
+	assert.Equal(t, "forever", value)
+}
+
+func TestSQLCacheCleanLoop(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	cache, err := NewSQLCache(ctx, db, SQLCleanOption(time.Millisecond*10, 0))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cache.Close()
+
+	for i := 0; i < 10; i++ {
+		err = cache.SetWithTTL(ctx, i, i, time.Millisecond)
+		assert.NoError(t, err)
+	}
+
+	assert.Eventually(t, func() bool {
+		var count int
+		err := db.QueryRow("SELECT COUNT(*) FROM cachex").Scan(&count)
+		return err == nil && count == 0
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestSQLCacheCachex(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+
+	cache, err := NewSQLCache(ctx, db, SQLDefaultTTLOption(time.Minute))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer cache.Close()
+
+	type Value struct {
+		ID   int
+		Name string
+	}
+	queries := 0
+	c := cachex.NewCachex(cache, cachex.QueryFunc(func(ctx context.Context, key, value interface{}) error {
+		queries++
+		*value.(*Value) = Value{ID: key.(int), Name: "name"}
+		return nil
+	}))
+
+	for i := 0; i < 3; i++ {
+		var value Value
+		err = c.Get(ctx, 1, &value)
+		assert.NoError(t, err)
+		assert.Equal(t, Value{ID: 1, Name: "name"}, value)
+	}
+	assert.Equal(t, 1, queries)
+}
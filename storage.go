@@ -39,6 +39,59 @@ type SetWithTTLableStorage interface {
 	SetWithTTL(ctx context.Context, key, value interface{}, TTL time.Duration) error
 }
 
+// WriteMode 写入模式
+type WriteMode int
+
+const (
+	// WriteOverwrite 覆盖写入，不论数据是否存在
+	WriteOverwrite WriteMode = iota
+
+	// WriteAdd 仅数据不存在时写入，同redis的SET NX
+	WriteAdd
+
+	// WriteReplace 仅数据存在时写入，同redis的SET XX
+	WriteReplace
+)
+
+// ModeWritableStorage 支持按写入模式写入的存储后端接口。
+// 已过期的数据（包括Get仍返回过期数据和Expired错误的数据）视为不存在
+type ModeWritableStorage interface {
+	Storage
+	// SetWithMode 按写入模式缓存数据，使用存储后端的默认TTL。返回是否写入
+	SetWithMode(ctx context.Context, key, value interface{}, mode WriteMode) (written bool, err error)
+}
+
+// CASStorage 支持比较并设置（compare-and-set）的存储后端接口。
+// 版本令牌标识key的一个版本，key每次写入、删除后版本都会变化，不存在的key也有版本。
+// 用于避免查询期间数据被删除（失效）后，迟到的查询结果写入过时的数据。
+type CASStorage interface {
+	Storage
+	// GetWithVersion 获取缓存的数据和版本令牌。错误语义同Get，没找到、已过期时同样返回版本令牌
+	GetWithVersion(ctx context.Context, key, value interface{}) (version uint64, err error)
+
+	// CompareAndSet 仅当key的版本仍为version时缓存数据，TTL为0时使用默认TTL。返回是否写入
+	CompareAndSet(ctx context.Context, key, value interface{}, version uint64, TTL time.Duration) (written bool, err error)
+}
+
+// LeasableStorage 支持租约（memcache风格）的存储后端接口。
+// 没找到、数据已过期时发放租约，删除key使该key未完成的租约失效，凭有效的租约才能写入。
+// 用于避免查询期间数据被删除（失效）后，迟到的查询结果写回过时的数据，并保留整个TTL。
+type LeasableStorage interface {
+	Storage
+	// GetWithLease 获取缓存的数据和租约令牌。错误语义同Get
+	GetWithLease(ctx context.Context, key, value interface{}) (lease uint64, err error)
+
+	// SetWithLease 凭租约缓存数据，TTL为0时使用默认TTL。租约已失效时放弃写入，返回false
+	SetWithLease(ctx context.Context, key, value interface{}, lease uint64, TTL time.Duration) (written bool, err error)
+}
+
+// AgeableStorage 支持获取缓存数据年龄（距写入的时长）的存储后端接口
+type AgeableStorage interface {
+	Storage
+	// GetWithAge 获取缓存的数据和数据的年龄。错误语义同Get
+	GetWithAge(ctx context.Context, key, value interface{}) (age time.Duration, err error)
+}
+
 // NopStorage 一个什么都不干的存储后端。
 // 可以用NopStorage加CacheX组合出一个单实例内不重复查询的机制。
 type NopStorage struct {
@@ -59,6 +112,31 @@ func (NopStorage) Get(ctx context.Context, key, value interface{}) error {
 	return nopNotFound{}
 }
 
+// GetWithAge 实现AgeableStorage接口，只返回NotFound错误。
+func (NopStorage) GetWithAge(ctx context.Context, key, value interface{}) (time.Duration, error) {
+	return 0, nopNotFound{}
+}
+
+// GetWithVersion 实现CASStorage接口，只返回NotFound错误。
+func (NopStorage) GetWithVersion(ctx context.Context, key, value interface{}) (uint64, error) {
+	return 0, nopNotFound{}
+}
+
+// CompareAndSet 实现CASStorage接口，只返回true和nil。
+func (NopStorage) CompareAndSet(ctx context.Context, key, value interface{}, version uint64, TTL time.Duration) (bool, error) {
+	return true, nil
+}
+
+// GetWithLease 实现LeasableStorage接口，只返回NotFound错误。
+func (NopStorage) GetWithLease(ctx context.Context, key, value interface{}) (uint64, error) {
+	return 0, nopNotFound{}
+}
+
+// SetWithLease 实现LeasableStorage接口，只返回true和nil。
+func (NopStorage) SetWithLease(ctx context.Context, key, value interface{}, lease uint64, TTL time.Duration) (bool, error) {
+	return true, nil
+}
+
 // Set 实现Storage接口，只返回nil。
 func (NopStorage) Set(ctx context.Context, key, value interface{}) error {
 	return nil
@@ -74,7 +152,24 @@ func (NopStorage) Clear(ctx context.Context) error {
 	return nil
 }
 
+// DelPattern 实现PatternDeletableStorage接口，只返回nil。
+func (NopStorage) DelPattern(ctx context.Context, pattern string) error {
+	return nil
+}
+
+// SetWithMode 实现ModeWritableStorage接口，只返回true和nil。
+func (NopStorage) SetWithMode(ctx context.Context, key, value interface{}, mode WriteMode) (bool, error) {
+	return true, nil
+}
+
 // SetWithTTL 实现SetWithTTLableStorage接口，只返回nil。
 func (NopStorage) SetWithTTL(ctx context.Context, key, value interface{}, TTL time.Duration) error {
 	return nil
 }
+
+// PatternDeletableStorage 支持按模式删除的存储后端接口
+type PatternDeletableStorage interface {
+	Storage
+	// DelPattern 删除key匹配glob模式的缓存数据。key先转为字符串（见KeyString）再匹配，模式语法见MatchPattern
+	DelPattern(ctx context.Context, pattern string) error
+}
@@ -0,0 +1,122 @@
+/*
+ * 异步回写
+ * 查询结果在后台更新到存储后端
+ *
+ * wencan
+ * 2026-10-19
+ */
+
+package cachex
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// writeBackPool 有界的后台回写协程池。
+// 同一个key的写操作总是由同一个协程按提交顺序执行，保证同一个key的写操作有序。
+type writeBackPool struct {
+	queues []chan func()
+
+	// lock 保护closed，避免向已关闭的队列提交任务
+	lock   sync.RWMutex
+	closed bool
+
+	wg sync.WaitGroup
+}
+
+func newWriteBackPool(workers, queueSize int) *writeBackPool {
+	if workers <= 0 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	pool := &writeBackPool{
+		queues: make([]chan func(), workers),
+	}
+	for idx := range pool.queues {
+		queue := make(chan func(), queueSize)
+		pool.queues[idx] = queue
+
+		pool.wg.Add(1)
+		go func() {
+			defer pool.wg.Done()
+			for task := range queue {
+				task()
+			}
+		}()
+	}
+	return pool
+}
+
+// queueOf 返回key所属的队列
+func (p *writeBackPool) queueOf(key interface{}) chan func() {
+	if len(p.queues) == 1 {
+		return p.queues[0]
+	}
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%T:%v", key, key)
+	return p.queues[h.Sum32()%uint32(len(p.queues))]
+}
+
+// submit 提交key的写任务。队列满时阻塞。已关闭返回false，任务不会执行
+func (p *writeBackPool) submit(key interface{}, task func()) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if p.closed {
+		return false
+	}
+	p.queueOf(key) <- task
+	return true
+}
+
+// do 提交key的写任务，并等待执行完成。已关闭则直接执行
+func (p *writeBackPool) do(key interface{}, task func() error) error {
+	var err error
+	done := make(chan struct{})
+	if !p.submit(key, func() {
+		defer close(done)
+		err = task()
+	}) {
+		return task()
+	}
+	<-done
+	return err
+}
+
+// flush 等待已提交的任务执行完成。已关闭直接返回
+func (p *writeBackPool) flush() {
+	p.lock.RLock()
+	if p.closed {
+		p.lock.RUnlock()
+		return
+	}
+	var wg sync.WaitGroup
+	for _, queue := range p.queues {
+		wg.Add(1)
+		queue <- wg.Done
+	}
+	p.lock.RUnlock()
+
+	wg.Wait()
+}
+
+// close 关闭，并等待已提交的任务执行完成
+func (p *writeBackPool) close() {
+	p.lock.Lock()
+	if p.closed {
+		p.lock.Unlock()
+		return
+	}
+	p.closed = true
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.lock.Unlock()
+
+	p.wg.Wait()
+}
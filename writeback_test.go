@@ -0,0 +1,149 @@
+package cachex
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/wencan/cachex/mock_cachex"
+)
+
+func TestCachexUseAsyncWriteBack(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+
+	// 阻塞回写，直到放行
+	release := make(chan struct{})
+
+	var lock sync.Mutex
+	notFound := mock_cachex.NewMockNotFound(ctrl)
+	cached := make(map[interface{}]interface{})
+	mockStorage := mock_cachex.NewMockDeletableStorage(ctrl)
+	mockStorage.EXPECT().Set(gomock.Any(), gomock.AssignableToTypeOf(1), gomock.Any()).DoAndReturn(func(ctx context.Context, key, value interface{}) error {
+		<-release
+		lock.Lock()
+		defer lock.Unlock()
+		cached[key] = value
+		return nil
+	}).AnyTimes()
+	mockStorage.EXPECT().Del(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, keys ...interface{}) error {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, key := range keys {
+			delete(cached, key)
+		}
+		return nil
+	}).AnyTimes()
+	mockStorage.EXPECT().Get(gomock.Any(), gomock.AssignableToTypeOf(1), gomock.Any()).DoAndReturn(func(ctx context.Context, key, value interface{}) error {
+		lock.Lock()
+		defer lock.Unlock()
+		v, exist := cached[key]
+		if !exist {
+			return notFound
+		}
+		reflect.ValueOf(value).Elem().Set(reflect.ValueOf(v))
+		return nil
+	}).AnyTimes()
+
+	var queried int
+	mockQuery := mock_cachex.NewMockQuerier(ctrl)
+	mockQuery.EXPECT().Query(gomock.Any(), gomock.AssignableToTypeOf(1), gomock.Any()).DoAndReturn(func(ctx context.Context, key, value interface{}) error {
+		queried++
+		reflect.ValueOf(value).Elem().Set(reflect.ValueOf(key))
+		return nil
+	}).AnyTimes()
+
+	c := NewCachex(mockStorage, mockQuery)
+	c.UseAsyncWriteBack(4, 16)
+
+	// 不等待回写完成即返回
+	for i := 0; i < 3; i++ {
+		var value int
+		err := c.Get(ctx, i, &value)
+		assert.NoError(t, err)
+		assert.Equal(t, i, value)
+	}
+
+	// 回写完成前，从哨兵得到结果，不重复查询
+	var value int
+	err := c.Get(ctx, 1, &value)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, 3, queried)
+
+	// 删除排在回写之后，不会被迟到的回写覆盖
+	deleted := make(chan error)
+	go func() {
+		deleted <- c.Del(ctx, 1)
+	}()
+	time.Sleep(time.Millisecond * 10)
+	close(release)
+	assert.NoError(t, <-deleted)
+
+	// 等待回写完成
+	c.Close()
+
+	lock.Lock()
+	_, exists := cached[1]
+	assert.False(t, exists)
+	assert.Equal(t, 2, len(cached))
+	lock.Unlock()
+
+	// 关闭后同步回写
+	err = c.Get(ctx, 10, &value)
+	assert.NoError(t, err)
+	lock.Lock()
+	assert.Equal(t, 10, cached[10])
+	lock.Unlock()
+}
+
+func TestCachexUseAsyncWriteBackTwice(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+
+	var lock sync.Mutex
+	cached := make(map[interface{}]interface{})
+	mockStorage := mock_cachex.NewMockStorage(ctrl)
+	mockStorage.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(mock_cachex.NewMockNotFound(ctrl)).AnyTimes()
+	mockStorage.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, key, value interface{}) error {
+		// 回写较慢
+		time.Sleep(time.Millisecond * 20)
+		lock.Lock()
+		defer lock.Unlock()
+		cached[key] = value
+		return nil
+	}).AnyTimes()
+	query := QueryFunc(func(ctx context.Context, key, value interface{}) error {
+		reflect.ValueOf(value).Elem().Set(reflect.ValueOf(key))
+		return nil
+	})
+
+	c := NewCachex(mockStorage, query)
+	c.UseAsyncWriteBack(1, 4)
+	var value int
+	err := c.Get(ctx, 1, &value)
+	assert.NoError(t, err)
+	previous := c.writeBack
+
+	// 再次设置，关闭之前的协程池，等待其中的回写完成
+	c.UseAsyncWriteBack(2, 4)
+	assert.True(t, previous.closed)
+	lock.Lock()
+	assert.Equal(t, 1, cached[1])
+	lock.Unlock()
+
+	err = c.Get(ctx, 2, &value)
+	assert.NoError(t, err)
+	c.Close()
+	lock.Lock()
+	assert.Equal(t, 2, cached[2])
+	lock.Unlock()
+}